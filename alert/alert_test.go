@@ -0,0 +1,283 @@
+package alert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+const testRules = `
+repeat_interval: 1h
+
+rules:
+  - name: HighMemory
+    expr: memory_usage_percent > 90
+    for: 2m
+  - name: Restarting
+    event: restart
+    count: 3
+    within: 10m
+
+notifiers:
+  - type: ntfy
+    url: https://ntfy.sh/cntm-alerts
+    headers:
+      Authorization: Bearer secret
+`
+
+func TestLoad(t *testing.T) {
+	file, err := ioutil.TempFile("", "cntm-alerts")
+	if err != nil {
+		t.Fatal("Failed to create a temporary file:", err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(testRules)
+	file.Close()
+
+	c, err := Load(file.Name())
+	if err != nil {
+		t.Fatal("Failed to load the rules:", err)
+	}
+
+	if c.RepeatInterval != time.Hour || len(c.Rules) != 2 || len(c.Notifiers) != 1 {
+		t.Errorf("Unexpected configuration: %+v", c)
+	}
+
+	if r := c.Rules[1]; r.Event != "restart" || r.Count != 3 || r.Within != 10*time.Minute {
+		t.Errorf("Unexpected event rule: %+v", r)
+	}
+
+	if n := c.Notifiers[0]; n.Type != "ntfy" || n.Headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Unexpected notifier: %+v", n)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, rules := range [][]RuleConfig{
+		{{Expr: "memory_usage_percent > 90"}},
+		{{Name: "a", Expr: "memory_usage_percent > 90"}, {Name: "a", Event: "die", Count: 1, Within: time.Minute}},
+		{{Name: "a", Expr: "unknown > 90"}},
+		{{Name: "a", Expr: "memory_usage_percent >> 90"}},
+		{{Name: "a", Expr: "memory_usage_percent > high"}},
+		{{Name: "a", Event: "die", Count: 0, Within: time.Minute}},
+		{{Name: "a", Expr: "memory_usage_percent > 90", Event: "die", Count: 1, Within: time.Minute}},
+		{{Name: "a"}},
+	} {
+		if _, err := compileRules(rules); err == nil {
+			t.Errorf("Expected an error for %+v", rules)
+		}
+	}
+
+	for _, notifiers := range [][]NotifierConfig{
+		{{Type: "email", URL: "http://localhost"}},
+		{{Type: "slack", URL: "not a url"}},
+	} {
+		if _, err := newNotifiers(notifiers); err == nil {
+			t.Errorf("Expected an error for %+v", notifiers)
+		}
+	}
+}
+
+func newTestEngine(t *testing.T, rules ...RuleConfig) (*Engine, *time.Time, *[]*Alert) {
+	e, err := NewEngine(Config{RepeatInterval: time.Hour, Rules: rules})
+	if err != nil {
+		t.Fatal("Failed to create the engine:", err)
+	}
+
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	var sent []*Alert
+
+	e.now = func() time.Time { return now }
+	e.send = func(a *Alert) { sent = append(sent, a) }
+
+	return e, &now, &sent
+}
+
+func TestStatsRule(t *testing.T) {
+	e, now, sent := newTestEngine(t, RuleConfig{Name: "HighMemory", Expr: "memory_usage_percent > 90", For: 2 * time.Minute})
+	defer e.Close()
+
+	c := &model.Container{Id: "c1", Name: "web"}
+	sample := func(percent float64) {
+		e.OnStats(c, &model.Stats{MemoryStats: model.MemoryStats{Percent: percent}})
+	}
+
+	sample(95)
+	*now = now.Add(time.Minute)
+	sample(50)
+	*now = now.Add(time.Minute)
+	sample(95)
+	*now = now.Add(time.Minute)
+	sample(96)
+
+	if len(*sent) != 0 {
+		t.Fatalf("Unexpected alerts before the pending time: %d", len(*sent))
+	}
+
+	*now = now.Add(time.Minute)
+	sample(97)
+	sample(98)
+
+	if len(*sent) != 1 || (*sent)[0].Status != Firing || (*sent)[0].Value != 97 {
+		t.Fatalf("Expected one firing alert: %+v", *sent)
+	}
+
+	*now = now.Add(time.Hour)
+	e.OnStats(c, &model.Stats{MemoryStats: model.MemoryStats{Percent: 99}})
+	e.evaluate()
+
+	if len(*sent) != 2 || (*sent)[1].Status != Firing {
+		t.Fatalf("Expected a repeated notification: %+v", *sent)
+	}
+
+	sample(40)
+
+	if len(*sent) != 3 || (*sent)[2].Status != Resolved || (*sent)[2].EndsAt == nil {
+		t.Fatalf("Expected a resolved alert: %+v", *sent)
+	}
+}
+
+func TestStaleStatsResolve(t *testing.T) {
+	e, now, sent := newTestEngine(t, RuleConfig{Name: "HighCPU", Expr: "cpu_usage_percent >= 80"})
+	defer e.Close()
+
+	e.OnStats(&model.Container{Id: "c1", Name: "web"}, &model.Stats{CpuStats: model.CpuStats{Percent: 80}})
+
+	*now = now.Add(staleAfter)
+	e.evaluate()
+
+	if len(*sent) != 2 || (*sent)[1].Status != Resolved || len(e.states) != 0 {
+		t.Errorf("Expected the stale alert to resolve: %+v", *sent)
+	}
+}
+
+func TestEventRule(t *testing.T) {
+	e, now, sent := newTestEngine(t, RuleConfig{Name: "Restarting", Event: RestartEvent, Count: 3, Within: 10 * time.Minute})
+	defer e.Close()
+
+	c := model.Container{Id: "c1", Name: "worker"}
+	event := func(action string) {
+		e.OnEvent(&model.Event{Action: action, Container: c})
+	}
+
+	event("start")
+	for idx := 0; idx < 3; idx++ {
+		*now = now.Add(time.Minute)
+		event("die")
+		event("start")
+	}
+
+	// restarting manually sends the restart event too
+	event("restart")
+
+	if len(*sent) != 1 || (*sent)[0].Status != Firing || (*sent)[0].Summary != "3 restart events within 10m0s" {
+		t.Fatalf("Expected one firing alert: %+v", *sent)
+	}
+
+	*now = now.Add(9 * time.Minute)
+	e.evaluate()
+
+	if len(*sent) != 2 || (*sent)[1].Status != Resolved {
+		t.Fatalf("Expected the alert to resolve after the window: %+v", *sent)
+	}
+}
+
+func TestHandOver(t *testing.T) {
+	previous, now, sent := newTestEngine(t,
+		RuleConfig{Name: "HighCPU", Expr: "cpu_usage_percent > 80"},
+		RuleConfig{Name: "HighMemory", Expr: "memory_usage_percent > 90"})
+
+	c := &model.Container{Id: "c1", Name: "web"}
+	previous.OnStats(c, &model.Stats{CpuStats: model.CpuStats{Percent: 90}, MemoryStats: model.MemoryStats{Percent: 95}})
+
+	if len(*sent) != 2 {
+		t.Fatalf("Expected two firing alerts: %+v", *sent)
+	}
+
+	next, nextNow, nextSent := newTestEngine(t, RuleConfig{Name: "HighCPU", Expr: "cpu_usage_percent > 85"})
+	defer next.Close()
+
+	*nextNow = *now
+
+	previous.HandOver(next)
+
+	// the alert of the removed rule resolves
+	if len(*sent) != 3 || (*sent)[2].Status != Resolved || (*sent)[2].Rule != "HighMemory" {
+		t.Fatalf("Expected the alert of the removed rule to resolve: %+v", *sent)
+	}
+
+	// the stats and events still in flight for the previous engine are ignored
+	previous.OnStats(c, &model.Stats{CpuStats: model.CpuStats{Percent: 50}})
+	previous.OnEvent(&model.Event{Action: "die", Container: *c})
+
+	if len(*sent) != 3 {
+		t.Fatalf("Unexpected notifications from the previous engine: %+v", *sent)
+	}
+
+	// the alert of the kept rule does not fire again
+	next.OnStats(c, &model.Stats{CpuStats: model.CpuStats{Percent: 90}})
+
+	if len(*nextSent) != 0 {
+		t.Fatalf("Unexpected notifications after the reload: %+v", *nextSent)
+	}
+
+	next.OnStats(c, &model.Stats{CpuStats: model.CpuStats{Percent: 50}})
+
+	if len(*nextSent) != 1 || (*nextSent)[0].Status != Resolved || (*nextSent)[0].Rule != "HighCPU" {
+		t.Fatalf("Expected the kept alert to resolve: %+v", *nextSent)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	a := &Alert{Status: Firing, Rule: "HighMemory", Host: "pi-01", Container: "web", Value: 95, Summary: "memory high"}
+
+	notifiers, err := newNotifiers([]NotifierConfig{
+		{Type: "webhook", URL: server.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Type: "slack", URL: server.URL + "/slack"},
+		{Type: "ntfy", URL: server.URL + "/topic"},
+	})
+	if err != nil {
+		t.Fatal("Failed to create the notifiers:", err)
+	}
+
+	for _, n := range notifiers {
+		if err := n.notify(server.Client(), a); err != nil {
+			t.Fatal("Failed to notify:", err)
+		}
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &decoded); err != nil || decoded["status"] != "firing" || decoded["container"] != "web" {
+		t.Errorf("Unexpected webhook body: %s", bodies[0])
+	}
+	if requests[0].Header.Get("Authorization") != "Bearer secret" {
+		t.Error("Expected the configured headers on the webhook")
+	}
+
+	if bodies[1] != `{"text":"[FIRING] HighMemory on web at pi-01: memory high"}` {
+		t.Errorf("Unexpected Slack body: %s", bodies[1])
+	}
+
+	if bodies[2] != "memory high" || requests[2].Header.Get("Title") != "[FIRING] HighMemory on web at pi-01" ||
+		requests[2].Header.Get("Priority") != "high" {
+		t.Errorf("Unexpected ntfy request: %s %v", bodies[2], requests[2].Header)
+	}
+}
@@ -0,0 +1,240 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rycus86/container-metrics/config"
+)
+
+const (
+	defaultRepeatInterval = 4 * time.Hour
+	defaultWithin         = 10 * time.Minute
+)
+
+// Config holds the alerting rules and the endpoints to notify
+type Config struct {
+	RepeatInterval time.Duration
+	Rules          []RuleConfig
+	Notifiers      []NotifierConfig
+}
+
+// RuleConfig is either a condition on the stats of the containers, like
+// `memory_usage_percent > 90` for 2 minutes, or a number of container events,
+// like 3 restarts within 10 minutes
+type RuleConfig struct {
+	Name string
+
+	Expr string
+	For  time.Duration
+
+	Event  string
+	Count  int
+	Within time.Duration
+}
+
+// NotifierConfig is an endpoint to send the notifications to,
+// with the webhook, slack or ntfy type
+type NotifierConfig struct {
+	Type    string
+	URL     string
+	Headers map[string]string
+}
+
+// Load reads the rules and the notifiers from the YAML file
+func Load(path string) (*Config, error) {
+	values, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parseConfig(values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if _, err := compileRules(c.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if _, err := newNotifiers(c.Notifiers); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return c, nil
+}
+
+func parseConfig(values map[string]interface{}) (*Config, error) {
+	c := &Config{RepeatInterval: defaultRepeatInterval}
+
+	for key, value := range values {
+		switch key {
+		case "repeat_interval":
+			duration, err := parseDuration(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			c.RepeatInterval = duration
+
+		case "rules":
+			items, err := parseMaps(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			for idx, fields := range items {
+				rule, err := parseRule(fields)
+				if err != nil {
+					return nil, fmt.Errorf("rule %d: %s", idx+1, err)
+				}
+
+				c.Rules = append(c.Rules, rule)
+			}
+
+		case "notifiers":
+			items, err := parseMaps(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			for idx, fields := range items {
+				notifier, err := parseNotifier(fields)
+				if err != nil {
+					return nil, fmt.Errorf("notifier %d: %s", idx+1, err)
+				}
+
+				c.Notifiers = append(c.Notifiers, notifier)
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown field: %s", key)
+		}
+	}
+
+	if len(c.Rules) == 0 {
+		return nil, fmt.Errorf("expected a rules list")
+	}
+
+	if c.RepeatInterval <= 0 {
+		return nil, fmt.Errorf("the repeat interval has to be positive: %s", c.RepeatInterval)
+	}
+
+	return c, nil
+}
+
+func parseRule(fields map[string]interface{}) (RuleConfig, error) {
+	rule := RuleConfig{Count: 1, Within: defaultWithin}
+
+	for key, value := range fields {
+		var err error
+
+		switch key {
+		case "name":
+			rule.Name, err = parseString(key, value)
+		case "expr":
+			rule.Expr, err = parseString(key, value)
+		case "for":
+			rule.For, err = parseDuration(key, value)
+		case "event":
+			rule.Event, err = parseString(key, value)
+		case "count":
+			var text string
+			if text, err = parseString(key, value); err == nil {
+				if rule.Count, err = strconv.Atoi(text); err != nil {
+					err = fmt.Errorf("invalid count: %s", text)
+				}
+			}
+		case "within":
+			rule.Within, err = parseDuration(key, value)
+		default:
+			err = fmt.Errorf("unknown field: %s", key)
+		}
+
+		if err != nil {
+			return rule, err
+		}
+	}
+
+	return rule, nil
+}
+
+func parseNotifier(fields map[string]interface{}) (NotifierConfig, error) {
+	notifier := NotifierConfig{}
+
+	for key, value := range fields {
+		var err error
+
+		switch key {
+		case "type":
+			notifier.Type, err = parseString(key, value)
+		case "url":
+			notifier.URL, err = parseString(key, value)
+		case "headers":
+			headers, ok := value.(map[string]interface{})
+			if !ok {
+				return notifier, fmt.Errorf("expected a map for headers")
+			}
+
+			notifier.Headers = map[string]string{}
+
+			for name, headerValue := range headers {
+				if notifier.Headers[name], err = parseString(name, headerValue); err != nil {
+					return notifier, err
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown field: %s", key)
+		}
+
+		if err != nil {
+			return notifier, err
+		}
+	}
+
+	return notifier, nil
+}
+
+func parseMaps(key string, value interface{}) ([]map[string]interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list for %s", key)
+	}
+
+	result := make([]map[string]interface{}, len(items))
+
+	for idx, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s %d: expected a map", key, idx+1)
+		}
+
+		result[idx] = fields
+	}
+
+	return result, nil
+}
+
+func parseString(key string, value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a plain value for %s", key)
+	}
+
+	return text, nil
+}
+
+func parseDuration(key string, value interface{}) (time.Duration, error) {
+	text, err := parseString(key, value)
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, text)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,395 @@
+package alert
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	evaluationInterval = 10 * time.Second
+	// the alerts on the stats of containers without new samples for this long are resolved
+	staleAfter = 5 * time.Minute
+)
+
+// state is the state of a rule for a container
+type state struct {
+	rule      *rule
+	container model.Container
+
+	pendingSince time.Time
+	firing       bool
+	startsAt     time.Time
+	notifiedAt   time.Time
+	seenAt       time.Time
+	value        float64
+
+	events []time.Time
+}
+
+// Engine evaluates the rules on every stats sample and container event,
+// and sends the notifications when the alerts fire, repeat or resolve
+type Engine struct {
+	config    Config
+	rules     []*rule
+	notifiers []notifier
+	client    *http.Client
+
+	lock       sync.Mutex
+	host       string
+	states     map[string]*state // by rule name and container ID
+	lastAction map[string]string // by container ID, to detect restarts
+	handedOver bool              // the states moved to the next engine
+
+	now    func() time.Time
+	send   func(*Alert)
+	sender sync.WaitGroup
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewEngine(config Config) (*Engine, error) {
+	rules, err := compileRules(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers, err := newNotifiers(config.Notifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.RepeatInterval <= 0 {
+		config.RepeatInterval = defaultRepeatInterval
+	}
+
+	e := &Engine{
+		config:    config,
+		rules:     rules,
+		notifiers: notifiers,
+		client:    &http.Client{Timeout: notifyTimeout},
+
+		states:     map[string]*state{},
+		lastAction: map[string]string{},
+
+		now: time.Now,
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	e.send = e.sendToNotifiers
+
+	go e.run()
+
+	return e, nil
+}
+
+func (e *Engine) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(evaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.evaluate()
+
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *Engine) getState(r *rule, c *model.Container) *state {
+	key := r.Name + "/" + c.Id
+
+	s, exists := e.states[key]
+	if !exists {
+		s = &state{rule: r, container: *c}
+		e.states[key] = s
+	}
+
+	return s
+}
+
+func (e *Engine) OnStats(c *model.Container, s *model.Stats) {
+	var alerts []*Alert
+
+	e.lock.Lock()
+
+	if e.handedOver {
+		e.lock.Unlock()
+		return
+	}
+
+	now := e.now()
+
+	for _, r := range e.rules {
+		if r.isEventRule() {
+			continue
+		}
+
+		value, ok := r.value(s)
+		if !ok {
+			continue
+		}
+
+		st := e.getState(r, c)
+		st.seenAt = now
+		st.value = value
+
+		if r.compare(value) {
+			if st.pendingSince.IsZero() {
+				st.pendingSince = now
+			}
+
+			if !st.firing && now.Sub(st.pendingSince) >= r.For {
+				alerts = append(alerts, e.fire(r, st, now))
+			}
+		} else {
+			st.pendingSince = time.Time{}
+
+			if st.firing {
+				alerts = append(alerts, e.resolve(r, st, now))
+			}
+		}
+	}
+
+	e.lock.Unlock()
+
+	e.notify(alerts)
+}
+
+func (e *Engine) OnEngineStats(s *model.EngineStats) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.host = s.Host
+}
+
+// OnEvent counts the container events for the rules, where a start
+// after the container died is also counted as a restart
+func (e *Engine) OnEvent(event *model.Event) {
+	var alerts []*Alert
+
+	e.lock.Lock()
+
+	if e.handedOver {
+		e.lock.Unlock()
+		return
+	}
+
+	now := e.now()
+
+	actions := []string{event.Action}
+
+	switch event.Action {
+	case "start":
+		if e.lastAction[event.Container.Id] == "die" {
+			actions = append(actions, RestartEvent)
+		}
+	case RestartEvent:
+		// the die and start events are sent for these too
+		actions = nil
+	}
+
+	if event.Action == "destroy" {
+		delete(e.lastAction, event.Container.Id)
+	} else if event.Action == "die" || event.Action == "start" {
+		e.lastAction[event.Container.Id] = event.Action
+	}
+
+	for _, action := range actions {
+		for _, r := range e.rules {
+			if r.Event != action {
+				continue
+			}
+
+			st := e.getState(r, &event.Container)
+			st.events = append(pruneEvents(st.events, now.Add(-r.Within)), now)
+			st.value = float64(len(st.events))
+
+			if !st.firing && len(st.events) >= r.Count {
+				alerts = append(alerts, e.fire(r, st, now))
+			}
+		}
+	}
+
+	e.lock.Unlock()
+
+	e.notify(alerts)
+}
+
+func pruneEvents(events []time.Time, since time.Time) []time.Time {
+	remaining := events[:0]
+
+	for _, t := range events {
+		if t.After(since) {
+			remaining = append(remaining, t)
+		}
+	}
+
+	return remaining
+}
+
+// evaluate resolves the alerts of expired events and stale stats,
+// and repeats the notifications of the ones still firing
+func (e *Engine) evaluate() {
+	var alerts []*Alert
+
+	e.lock.Lock()
+
+	now := e.now()
+
+	for key, st := range e.states {
+		r := st.rule
+
+		var expired, unused bool
+
+		if r.isEventRule() {
+			st.events = pruneEvents(st.events, now.Add(-r.Within))
+			st.value = float64(len(st.events))
+
+			expired = len(st.events) < r.Count
+			unused = len(st.events) == 0
+		} else {
+			expired = now.Sub(st.seenAt) >= staleAfter
+			unused = expired
+		}
+
+		if st.firing && expired {
+			alerts = append(alerts, e.resolve(r, st, now))
+		} else if st.firing && now.Sub(st.notifiedAt) >= e.config.RepeatInterval {
+			st.notifiedAt = now
+			alerts = append(alerts, e.alert(r, st, Firing, nil))
+		}
+
+		if !st.firing && unused {
+			delete(e.states, key)
+		}
+	}
+
+	e.lock.Unlock()
+
+	e.notify(alerts)
+}
+
+func (e *Engine) fire(r *rule, st *state, now time.Time) *Alert {
+	st.firing = true
+	st.startsAt = now
+	st.notifiedAt = now
+
+	return e.alert(r, st, Firing, nil)
+}
+
+func (e *Engine) resolve(r *rule, st *state, now time.Time) *Alert {
+	st.firing = false
+	st.pendingSince = time.Time{}
+
+	return e.alert(r, st, Resolved, &now)
+}
+
+func (e *Engine) alert(r *rule, st *state, status string, endsAt *time.Time) *Alert {
+	return &Alert{
+		Status:    status,
+		Rule:      r.Name,
+		Host:      e.host,
+		Container: st.container.Name,
+		Image:     st.container.Image,
+		ID:        st.container.Id,
+		Value:     st.value,
+		Summary:   r.summary(st.value),
+		StartsAt:  st.startsAt,
+		EndsAt:    endsAt,
+	}
+}
+
+func (e *Engine) notify(alerts []*Alert) {
+	for _, a := range alerts {
+		log.Println("Alert", a.Title()+":", a.Summary)
+
+		e.send(a)
+	}
+}
+
+func (e *Engine) sendToNotifiers(a *Alert) {
+	for _, n := range e.notifiers {
+		e.sender.Add(1)
+
+		go func(n notifier) {
+			defer e.sender.Done()
+
+			if err := n.notify(e.client, a); err != nil {
+				log.Println("Failed to send the notification for", a.Rule, err)
+			}
+		}(n)
+	}
+}
+
+// HandOver moves the state of the alerts to the next engine for the rules that still exist
+// there, resolves the alerts of the other rules, then closes this engine; the next engine can be nil
+func (e *Engine) HandOver(next *Engine) error {
+	var alerts []*Alert
+
+	e.lock.Lock()
+
+	now := e.now()
+	rules := map[string]*rule{}
+
+	if next != nil {
+		next.lock.Lock()
+
+		for _, r := range next.rules {
+			rules[r.Name] = r
+		}
+
+		if next.host == "" {
+			next.host = e.host
+		}
+
+		for id, action := range e.lastAction {
+			next.lastAction[id] = action
+		}
+	}
+
+	for key, st := range e.states {
+		if r, exists := rules[st.rule.Name]; exists && r.isEventRule() == st.rule.isEventRule() {
+			// the next engine may have fired already since it gets the stats and events
+			if started, ok := next.states[key]; !ok || !started.firing {
+				st.rule = r
+				next.states[key] = st
+			}
+		} else if st.firing {
+			alerts = append(alerts, e.resolve(st.rule, st, now))
+		}
+	}
+
+	// the stats and events still in flight for this engine are ignored from now on
+	e.states = map[string]*state{}
+	e.handedOver = true
+
+	if next != nil {
+		next.lock.Unlock()
+	}
+
+	e.lock.Unlock()
+
+	e.notify(alerts)
+
+	return e.Close()
+}
+
+// Close stops the evaluation, and waits for the notifications in progress
+func (e *Engine) Close() error {
+	close(e.done)
+	<-e.stopped
+
+	e.sender.Wait()
+	return nil
+}
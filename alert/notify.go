@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Alert is the notification about a rule firing or resolving for a container
+type Alert struct {
+	Status    string     `json:"status"`
+	Rule      string     `json:"rule"`
+	Host      string     `json:"host,omitempty"`
+	Container string     `json:"container"`
+	Image     string     `json:"image,omitempty"`
+	ID        string     `json:"container_id"`
+	Value     float64    `json:"value"`
+	Summary   string     `json:"summary"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+}
+
+const (
+	Firing   = "firing"
+	Resolved = "resolved"
+)
+
+// Title returns a short description, like `[FIRING] HighMemory on web`
+func (a *Alert) Title() string {
+	title := "[" + strings.ToUpper(a.Status) + "] " + a.Rule + " on " + a.Container
+	if a.Host != "" {
+		title += " at " + a.Host
+	}
+
+	return title
+}
+
+type notifier interface {
+	notify(client *http.Client, a *Alert) error
+}
+
+func newNotifiers(configs []NotifierConfig) ([]notifier, error) {
+	notifiers := make([]notifier, len(configs))
+
+	for idx, c := range configs {
+		if parsed, err := url.Parse(c.URL); err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("notifier %d: invalid URL: %s", idx+1, c.URL)
+		}
+
+		switch c.Type {
+		case "webhook":
+			notifiers[idx] = &webhookNotifier{c}
+		case "slack":
+			notifiers[idx] = &slackNotifier{c}
+		case "ntfy":
+			notifiers[idx] = &ntfyNotifier{c}
+		default:
+			return nil, fmt.Errorf("notifier %d: unknown type: %s", idx+1, c.Type)
+		}
+	}
+
+	return notifiers, nil
+}
+
+// webhookNotifier posts the alerts as JSON
+type webhookNotifier struct {
+	config NotifierConfig
+}
+
+func (n *webhookNotifier) notify(client *http.Client, a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return post(client, n.config, bytes.NewReader(body), map[string]string{"Content-Type": "application/json"})
+}
+
+// slackNotifier posts the alerts to incoming webhooks compatible with Slack
+type slackNotifier struct {
+	config NotifierConfig
+}
+
+func (n *slackNotifier) notify(client *http.Client, a *Alert) error {
+	body, err := json.Marshal(map[string]string{"text": a.Title() + ": " + a.Summary})
+	if err != nil {
+		return err
+	}
+
+	return post(client, n.config, bytes.NewReader(body), map[string]string{"Content-Type": "application/json"})
+}
+
+// ntfyNotifier publishes the alerts to a topic of an ntfy server
+type ntfyNotifier struct {
+	config NotifierConfig
+}
+
+func (n *ntfyNotifier) notify(client *http.Client, a *Alert) error {
+	headers := map[string]string{"Title": a.Title()}
+
+	if a.Status == Firing {
+		headers["Priority"] = "high"
+		headers["Tags"] = "warning"
+	} else {
+		headers["Tags"] = "white_check_mark"
+	}
+
+	return post(client, n.config, strings.NewReader(a.Summary), headers)
+}
+
+func post(client *http.Client, c NotifierConfig, body io.Reader, headers map[string]string) error {
+	request, err := http.NewRequest("POST", c.URL, body)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	for name, value := range c.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("unexpected response: %s %s", response.Status, bytes.TrimSpace(message))
+	}
+
+	io.Copy(ioutil.Discard, response.Body)
+	return nil
+}
@@ -0,0 +1,161 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// RestartEvent is the event of a container starting again after it died,
+// either by its restart policy or manually
+const RestartEvent = "restart"
+
+var expression = regexp.MustCompile(`^\s*([a-z_]+)\s*(>=|<=|==|!=|>|<)\s*(\S+)\s*$`)
+
+// statsValues are the values of the stats the rules can compare,
+// the ones based on rates are not available for the first sample
+var statsValues = map[string]func(*model.Stats) (float64, bool){
+	"cpu_usage_percent": func(s *model.Stats) (float64, bool) {
+		return s.CpuStats.Percent, true
+	},
+	"cpu_usage_cores": func(s *model.Stats) (float64, bool) {
+		return rate(s, func(r *model.Rates) float64 { return r.CpuCores })
+	},
+	"memory_total_bytes": func(s *model.Stats) (float64, bool) {
+		return float64(s.MemoryStats.Total), true
+	},
+	"memory_usage_bytes": func(s *model.Stats) (float64, bool) {
+		return s.MemoryStats.Usage, true
+	},
+	"memory_usage_percent": func(s *model.Stats) (float64, bool) {
+		return s.MemoryStats.Percent, true
+	},
+	"io_read_bytes_per_second": func(s *model.Stats) (float64, bool) {
+		return rate(s, func(r *model.Rates) float64 { return r.IORead })
+	},
+	"io_write_bytes_per_second": func(s *model.Stats) (float64, bool) {
+		return rate(s, func(r *model.Rates) float64 { return r.IOWritten })
+	},
+	"net_rx_bytes_per_second": func(s *model.Stats) (float64, bool) {
+		return rate(s, func(r *model.Rates) float64 { return r.RxBytes })
+	},
+	"net_tx_bytes_per_second": func(s *model.Stats) (float64, bool) {
+		return rate(s, func(r *model.Rates) float64 { return r.TxBytes })
+	},
+	"pids_current": func(s *model.Stats) (float64, bool) {
+		return float64(s.PidsStats.Current), true
+	},
+}
+
+func rate(s *model.Stats, value func(*model.Rates) float64) (float64, bool) {
+	if s.Rates == nil {
+		return 0, false
+	}
+
+	return value(s.Rates), true
+}
+
+// rule is a validated alerting rule
+type rule struct {
+	RuleConfig
+
+	value   func(*model.Stats) (float64, bool)
+	compare func(float64) bool
+}
+
+func compileRules(configs []RuleConfig) ([]*rule, error) {
+	rules := make([]*rule, len(configs))
+	names := map[string]bool{}
+
+	for idx, c := range configs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("rule %d: the name is required", idx+1)
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("rule %d: duplicate name: %s", idx+1, c.Name)
+		}
+
+		names[c.Name] = true
+
+		r := &rule{RuleConfig: c}
+
+		switch {
+		case c.Expr != "" && c.Event != "":
+			return nil, fmt.Errorf("rule %s: only one of expr and event can be set", c.Name)
+
+		case c.Expr != "":
+			if err := r.parseExpr(); err != nil {
+				return nil, fmt.Errorf("rule %s: %s", c.Name, err)
+			}
+
+		case c.Event != "":
+			if c.Count < 1 || c.Within <= 0 {
+				return nil, fmt.Errorf("rule %s: the count and the time window have to be positive", c.Name)
+			}
+
+		default:
+			return nil, fmt.Errorf("rule %s: either expr or event is required", c.Name)
+		}
+
+		rules[idx] = r
+	}
+
+	return rules, nil
+}
+
+func (r *rule) parseExpr() error {
+	match := expression.FindStringSubmatch(r.Expr)
+	if match == nil {
+		return fmt.Errorf("invalid expression, expected `<value> <operator> <number>`: %s", r.Expr)
+	}
+
+	value, exists := statsValues[match[1]]
+	if !exists {
+		return fmt.Errorf("unknown value: %s", match[1])
+	}
+
+	threshold, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return fmt.Errorf("invalid number: %s", match[3])
+	}
+
+	r.value = value
+
+	switch match[2] {
+	case ">":
+		r.compare = func(v float64) bool { return v > threshold }
+	case ">=":
+		r.compare = func(v float64) bool { return v >= threshold }
+	case "<":
+		r.compare = func(v float64) bool { return v < threshold }
+	case "<=":
+		r.compare = func(v float64) bool { return v <= threshold }
+	case "==":
+		r.compare = func(v float64) bool { return v == threshold }
+	case "!=":
+		r.compare = func(v float64) bool { return v != threshold }
+	}
+
+	return nil
+}
+
+func (r *rule) isEventRule() bool {
+	return r.Event != ""
+}
+
+func (r *rule) summary(value float64) string {
+	if r.isEventRule() {
+		return fmt.Sprintf("%d %s events within %s", int(value), r.Event, r.Within)
+	}
+
+	summary := strings.TrimSpace(r.Expr)
+	if r.For > 0 {
+		summary += " for " + r.For.String()
+	}
+
+	return summary + " (current value: " + strconv.FormatFloat(value, 'f', 2, 64) + ")"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/rycus86/container-metrics/metrics"
+)
+
+// collectorFlag enables or disables a collector with --collector.X,
+// or the opposite with --no-collector.X, where the last one set wins
+type collectorFlag struct {
+	name    string
+	negate  bool
+	enabled map[string]bool
+}
+
+func (f *collectorFlag) String() string {
+	if f.enabled == nil {
+		return ""
+	}
+
+	if enabled, exists := f.enabled[f.name]; exists {
+		return strconv.FormatBool(enabled != f.negate)
+	}
+
+	return ""
+}
+
+func (f *collectorFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	f.enabled[f.name] = enabled != f.negate
+	return nil
+}
+
+func (f *collectorFlag) IsBoolFlag() bool {
+	return true
+}
+
+// registerCollectors adds the flags to toggle the registered collectors
+func registerCollectors(fs *flag.FlagSet, enabled map[string]bool) {
+	for _, info := range metrics.Collectors() {
+		state := "disabled"
+		if info.EnabledByDefault {
+			state = "enabled"
+		}
+
+		fs.Var(&collectorFlag{name: info.Name, enabled: enabled}, "collector."+info.Name,
+			"Enable the "+info.Name+" collector: "+info.Description+" ("+state+" by default)")
+		fs.Var(&collectorFlag{name: info.Name, negate: true, enabled: enabled}, "no-collector."+info.Name,
+			"Disable the "+info.Name+" collector")
+	}
+}
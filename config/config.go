@@ -0,0 +1,115 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Load reads the YAML configuration file
+func Load(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parseYAML(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return values, nil
+}
+
+// Apply sets the flags from the configuration values, where the nested keys
+// are joined with dashes to get the names of the flags, so that
+// `influxdb: {url: ...}` sets the -influxdb-url flag, for example.
+// For flags with dots in their names, like -collector.cpu, the last key
+// can be joined with a dot too, as in `collector: {cpu: false}`.
+// Lists are set as comma separated values, and maps as comma separated
+// key=value pairs, for the flags that expect them.
+func Apply(fs *flag.FlagSet, values map[string]interface{}, ignored ...string) error {
+	return apply(fs, "", values, ignored)
+}
+
+func apply(fs *flag.FlagSet, prefix string, values map[string]interface{}, ignored []string) error {
+	for _, key := range sortedKeys(values) {
+		name := prefix + strings.Replace(key, "_", "-", -1)
+
+		if prefix != "" && fs.Lookup(name) == nil {
+			if dotted := strings.TrimSuffix(prefix, "-") + "." + key; fs.Lookup(dotted) != nil {
+				name = dotted
+			}
+		}
+
+		for _, ignore := range ignored {
+			if name == ignore {
+				return fmt.Errorf("the %s option cannot be set in the configuration file", name)
+			}
+		}
+
+		if fs.Lookup(name) == nil {
+			nested, ok := values[key].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unknown option: %s", name)
+			}
+
+			if err := apply(fs, name+"-", nested, ignored); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var value string
+
+		switch v := values[key].(type) {
+		case string:
+			value = v
+		case []interface{}:
+			items := make([]string, 0, len(v))
+
+			for _, item := range v {
+				itemValue, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("invalid value for %s: only lists of plain values are supported", name)
+				}
+
+				items = append(items, itemValue)
+			}
+
+			value = strings.Join(items, ",")
+		case map[string]interface{}:
+			pairs := make([]string, 0, len(v))
+
+			for _, item := range sortedKeys(v) {
+				itemValue, ok := v[item].(string)
+				if !ok {
+					return fmt.Errorf("invalid value for %s: %s", name, item)
+				}
+
+				pairs = append(pairs, item+"="+itemValue)
+			}
+
+			value = strings.Join(pairs, ",")
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+# HTTP settings
+port: 9090
+interval: "10s"   # quoted values are fine too
+
+labels:
+  - com.docker.compose.service
+  - 'com.example.team'
+
+influxdb:
+  url: http://influxdb:8086
+  database: metrics
+
+otlp:
+  endpoint: http://collector:4318
+  headers:
+    Authorization: "Bearer secret # not a comment"
+    X-Scope: home
+
+remote_write:
+  labels: [env=home, "site=pi, rack 1"]
+`
+
+func TestParseYAML(t *testing.T) {
+	values, err := parseYAML(testConfig)
+	if err != nil {
+		t.Fatal("Failed to parse:", err)
+	}
+
+	expected := map[string]interface{}{
+		"port":     "9090",
+		"interval": "10s",
+		"labels":   []interface{}{"com.docker.compose.service", "com.example.team"},
+		"influxdb": map[string]interface{}{
+			"url":      "http://influxdb:8086",
+			"database": "metrics",
+		},
+		"otlp": map[string]interface{}{
+			"endpoint": "http://collector:4318",
+			"headers": map[string]interface{}{
+				"Authorization": "Bearer secret # not a comment",
+				"X-Scope":       "home",
+			},
+		},
+		"remote_write": map[string]interface{}{
+			"labels": []interface{}{"env=home", "site=pi, rack 1"},
+		},
+	}
+
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Unexpected values:\n%#v", values)
+	}
+}
+
+func TestParseInvalidYAML(t *testing.T) {
+	for _, content := range []string{
+		"port 8080",
+		"port: 8080\n  interval: 5s",
+		"rules:\n  - name: a\n     value: b",
+		"port: 8080\nport: 9090",
+		"\tport: 8080",
+		`port: "8080`,
+		"labels: [a, b",
+		"- port: 8080",
+	} {
+		if _, err := parseYAML(content); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	var (
+		port     int
+		interval time.Duration
+		labels   string
+		url      string
+		headers  string
+		cpu      bool
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.IntVar(&port, "port", 8080, "")
+	fs.DurationVar(&interval, "interval", 5*time.Second, "")
+	fs.StringVar(&labels, "labels", "", "")
+	fs.StringVar(&url, "influxdb-url", "", "")
+	fs.StringVar(&headers, "otlp-headers", "", "")
+	fs.String("config", "", "")
+	fs.BoolVar(&cpu, "collector.cpu", true, "")
+
+	values, _ := parseYAML(`
+port: 9090
+interval: 10s
+labels: [a, b]
+influxdb:
+  url: http://influxdb:8086
+otlp:
+  headers:
+    b: 2
+    a: 1
+collector:
+  cpu: false
+`)
+
+	if err := Apply(fs, values); err != nil {
+		t.Fatal("Failed to apply:", err)
+	}
+
+	if port != 9090 || interval != 10*time.Second || labels != "a,b" ||
+		url != "http://influxdb:8086" || headers != "a=1,b=2" || cpu {
+		t.Error("Unexpected values:", port, interval, labels, url, headers, cpu)
+	}
+
+	for _, content := range []string{"unknown: 1", "port: abc", "influxdb:\n  unknown: x", "config: other.yml"} {
+		values, _ := parseYAML(content)
+
+		if err := Apply(fs, values, "config"); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestParseFlowStyle(t *testing.T) {
+	values, err := parseYAML("influxdb: {url: http://influxdb:8086, database: metrics}\nlabels: [1.10, true, ~]")
+	if err != nil {
+		t.Fatal("Failed to parse:", err)
+	}
+
+	expected := map[string]interface{}{
+		"influxdb": map[string]interface{}{"url": "http://influxdb:8086", "database": "metrics"},
+		"labels":   []interface{}{"1.10", "true", ""},
+	}
+
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Unexpected values:\n%#v", values)
+	}
+}
+
+func TestParseListOfMaps(t *testing.T) {
+	values, err := parseYAML(`
+rules:
+  - source: [a, b]
+    regex: "(.*)"
+  -   action: drop
+      target: x
+  - plain
+`)
+	if err != nil {
+		t.Fatal("Failed to parse:", err)
+	}
+
+	expected := []interface{}{
+		map[string]interface{}{"source": []interface{}{"a", "b"}, "regex": "(.*)"},
+		map[string]interface{}{"action": "drop", "target": "x"},
+		"plain",
+	}
+
+	if !reflect.DeepEqual(values["rules"], expected) {
+		t.Errorf("Unexpected values:\n%#v", values["rules"])
+	}
+}
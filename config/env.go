@@ -0,0 +1,59 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// EnvironmentName returns the name of the environment variable for the flag,
+// like CNTM_INFLUXDB_URL for -influxdb-url or CNTM_COLLECTOR_CPU
+// for -collector.cpu with the CNTM prefix
+func EnvironmentName(prefix, name string) string {
+	return prefix + "_" + strings.ToUpper(environmentReplacer.Replace(name))
+}
+
+var environmentReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// ApplyEnvironment sets the flags from the environment variables named after them,
+// or from the contents of the files in the variables with the _FILE suffix,
+// like the ones used with Docker secrets. The shorthand flags are skipped.
+func ApplyEnvironment(fs *flag.FlagSet, prefix string, lookup func(string) (string, bool)) error {
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || len(f.Name) == 1 {
+			return
+		}
+
+		name := EnvironmentName(prefix, f.Name)
+
+		value, hasValue := lookup(name)
+		path, hasFile := lookup(name + "_FILE")
+
+		if hasValue && hasFile {
+			err = fmt.Errorf("only one of %s and %s_FILE can be set", name, name)
+			return
+		}
+
+		if hasFile {
+			content, readErr := ioutil.ReadFile(path)
+			if readErr != nil {
+				err = fmt.Errorf("failed to read %s_FILE: %s", name, readErr)
+				return
+			}
+
+			// files usually end with a new line
+			value, hasValue = strings.TrimRight(string(content), "\r\n"), true
+		}
+
+		if hasValue {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %s: %s", name, setErr)
+			}
+		}
+	})
+
+	return err
+}
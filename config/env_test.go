@@ -0,0 +1,71 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	secret, err := ioutil.TempFile("", "cntm-secret")
+	if err != nil {
+		t.Fatal("Failed to create a temporary file:", err)
+	}
+	defer os.Remove(secret.Name())
+
+	secret.WriteString("s3cret\n")
+	secret.Close()
+
+	var port int
+	var url, password string
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.IntVar(&port, "port", 8080, "")
+	fs.IntVar(&port, "p", 8080, "")
+	fs.StringVar(&url, "influxdb-url", "", "")
+	fs.StringVar(&password, "influxdb-password", "", "")
+
+	environment := map[string]string{
+		"CNTM_P":                      "1234",
+		"CNTM_PORT":                   "9090",
+		"CNTM_INFLUXDB_URL":           "http://influxdb:8086",
+		"CNTM_INFLUXDB_PASSWORD_FILE": secret.Name(),
+	}
+
+	lookup := func(name string) (string, bool) {
+		value, ok := environment[name]
+		return value, ok
+	}
+
+	if err := ApplyEnvironment(fs, "CNTM", lookup); err != nil {
+		t.Fatal("Failed to apply the environment:", err)
+	}
+
+	if port != 9090 || url != "http://influxdb:8086" || password != "s3cret" {
+		t.Error("Unexpected values:", port, url, password)
+	}
+
+	environment["CNTM_INFLUXDB_PASSWORD"] = "other"
+	if err := ApplyEnvironment(fs, "CNTM", lookup); err == nil {
+		t.Error("Expected an error for both the value and the file set")
+	}
+
+	delete(environment, "CNTM_INFLUXDB_PASSWORD")
+	environment["CNTM_PORT"] = "abc"
+	if err := ApplyEnvironment(fs, "CNTM", lookup); err == nil {
+		t.Error("Expected an error for the invalid value")
+	}
+}
+
+func TestEnvironmentName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"port":             "CNTM_PORT",
+		"influxdb-url":     "CNTM_INFLUXDB_URL",
+		"no-collector.cpu": "CNTM_NO_COLLECTOR_CPU",
+	} {
+		if actual := EnvironmentName("CNTM", name); actual != expected {
+			t.Errorf("Unexpected name for %s: %s", name, actual)
+		}
+	}
+}
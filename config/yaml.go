@@ -0,0 +1,69 @@
+package config
+
+import (
+	"gopkg.in/yaml.v2"
+)
+
+// yamlValue is a node of the YAML document, where the maps are kept
+// as map[string]interface{}, the lists as []interface{}, and the scalars
+// as strings, with the text they were written with in the file
+type yamlValue struct {
+	value interface{}
+}
+
+func (v *yamlValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err == nil {
+		v.value = text
+		return nil
+	}
+
+	var list []yamlValue
+	if err := unmarshal(&list); err == nil {
+		items := make([]interface{}, len(list))
+		for idx, item := range list {
+			items[idx] = item.get()
+		}
+
+		v.value = items
+		return nil
+	}
+
+	var fields map[string]yamlValue
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for key, item := range fields {
+		values[key] = item.get()
+	}
+
+	v.value = values
+	return nil
+}
+
+// get returns the value, with empty strings for the null values
+func (v yamlValue) get() interface{} {
+	if v.value == nil {
+		return ""
+	}
+
+	return v.value
+}
+
+// parseYAML parses the configuration file with maps on the top level
+func parseYAML(content string) (map[string]interface{}, error) {
+	var fields map[string]yamlValue
+
+	if err := yaml.UnmarshalStrict([]byte(content), &fields); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for key, item := range fields {
+		values[key] = item.get()
+	}
+
+	return values, nil
+}
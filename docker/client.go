@@ -5,23 +5,36 @@ import (
 	"encoding/json"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	dockerClient "github.com/docker/docker/client"
 
 	"github.com/rycus86/container-metrics/model"
+	"github.com/rycus86/container-metrics/relabel"
 	"regexp"
 )
 
 type Client struct {
-	client       *dockerClient.Client
+	client *dockerClient.Client
+
+	lock         sync.Mutex
 	timeout      time.Duration
 	labelFilters []string
+	relabelRules []*relabel.Rule
+	filter       *ContainerFilter
+	eventHandler func(*model.Event)
 }
 
-func NewClient(timeout time.Duration, labelFilters []string) (*Client, error) {
-	cli, err := dockerClient.NewClientWithOpts(dockerClient.FromEnv, dockerClient.WithVersion(""))
+func NewClient(host string, timeout time.Duration, labelFilters []string) (*Client, error) {
+	options := []func(*dockerClient.Client) error{dockerClient.FromEnv, dockerClient.WithVersion("")}
+	if host != "" {
+		options = append(options, dockerClient.WithHost(host))
+	}
+
+	cli, err := dockerClient.NewClientWithOpts(options...)
 	if err != nil {
 		return nil, err
 	}
@@ -33,8 +46,76 @@ func NewClient(timeout time.Duration, labelFilters []string) (*Client, error) {
 	}, nil
 }
 
+// Configure changes the timeout and the label filters of the client
+func (c *Client) Configure(timeout time.Duration, labelFilters []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.timeout = timeout
+	c.labelFilters = labelFilters
+}
+
+// SetRelabelRules changes the relabeling rules applied to the containers
+func (c *Client) SetRelabelRules(rules []*relabel.Rule) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.relabelRules = rules
+}
+
+// SetContainerFilter changes the filter selecting the containers to collect the metrics of
+func (c *Client) SetContainerFilter(filter *ContainerFilter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.filter = filter
+}
+
+func (c *Client) getContainerFilter() *ContainerFilter {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.filter
+}
+
+// SetEventHandler changes the function called with the container events
+func (c *Client) SetEventHandler(handler func(*model.Event)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.eventHandler = handler
+}
+
+func (c *Client) getEventHandler() func(*model.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.eventHandler
+}
+
+func (c *Client) getRelabelRules() []*relabel.Rule {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.relabelRules
+}
+
+func (c *Client) getTimeout() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.timeout
+}
+
+func (c *Client) getLabelFilters() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.labelFilters
+}
+
 func (c *Client) GetEngineStats() (*model.EngineStats, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.getTimeout())
 	defer cancel()
 
 	info, err := c.client.Info(ctx)
@@ -53,33 +134,43 @@ func (c *Client) GetEngineStats() (*model.EngineStats, error) {
 }
 
 func (c *Client) GetContainers() ([]model.Container, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.getTimeout())
 	defer cancel()
 
-	dockerContainers, err := c.client.ContainerList(ctx, dockerTypes.ContainerListOptions{})
+	filter := c.getContainerFilter()
+
+	listOptions := dockerTypes.ContainerListOptions{}
+	if filter != nil {
+		listOptions.Filters = filter.dockerFilters
+	}
+
+	dockerContainers, err := c.client.ContainerList(ctx, listOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	containers := make([]model.Container, len(dockerContainers))
+	containers := make([]model.Container, 0, len(dockerContainers))
 	mapped := map[string]model.Container{}
 
-	for idx, item := range dockerContainers {
-		imageName := item.Image
-
-		// strip the hash after the @ if present
-		if atIndex := strings.Index(imageName, "@"); atIndex >= 0 {
-			imageName = imageName[0:atIndex]
-		}
-
-		containers[idx] = model.Container{
+	for _, item := range dockerContainers {
+		container := model.Container{
 			Id:     item.ID,
 			Name:   getContainerName(item),
 			Image:  getContainerImage(item),
-			Labels: c.getLabelsFor(item),
+			Health: getContainerHealth(item),
+
+			ImageID: item.ImageID,
 		}
 
-		mapped[item.ID] = containers[idx]
+		container.ImageRepository, container.ImageTag, container.ImageDigest = parseImageReference(item.Image)
+
+		if !c.selectContainer(filter, &container, item.Labels) {
+			continue
+		}
+
+		containers = append(containers, container)
+
+		mapped[item.ID] = container
 	}
 
 	return containers, nil
@@ -100,16 +191,103 @@ func getContainerImage(c dockerTypes.Container) string {
 	return imageName
 }
 
-func (c *Client) getLabelsFor(dc dockerTypes.Container) map[string]string {
+// parseImageReference splits the image reference into the repository, the tag and the digest,
+// like `registry:5000/app:1.0@sha256:...`, with the latest tag if neither the tag nor the digest is set
+func parseImageReference(reference string) (string, string, string) {
+	if strings.HasPrefix(reference, "sha256:") {
+		// the container was started from an image ID, or its tag has moved to another image
+		return "", "", ""
+	}
+
+	repository, tag, digest := reference, "", ""
+
+	if atIndex := strings.Index(repository, "@"); atIndex >= 0 {
+		repository, digest = repository[:atIndex], repository[atIndex+1:]
+	}
+
+	// the registry host in the first part can have a port too
+	if colonIndex := strings.LastIndex(repository, ":"); colonIndex > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:colonIndex], repository[colonIndex+1:]
+	}
+
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+
+	return repository, tag, digest
+}
+
+// getContainerHealth parses the health check status from the end of the status,
+// like `Up 2 hours (healthy)` or `Up 5 seconds (health: starting)`
+func getContainerHealth(c dockerTypes.Container) string {
+	switch {
+	case strings.HasSuffix(c.Status, "(healthy)"):
+		return model.Healthy
+	case strings.HasSuffix(c.Status, "(unhealthy)"):
+		return model.Unhealthy
+	case strings.HasSuffix(c.Status, "(health: starting)"):
+		return model.Starting
+	default:
+		return ""
+	}
+}
+
+// selectContainer applies the container filter and the relabeling rules, and sets
+// the resulting labels on the container, or returns false if it is not collected
+func (c *Client) selectContainer(filter *ContainerFilter, container *model.Container, labels map[string]string) bool {
+	if filter != nil && !filter.Matches(container, labels) {
+		return false
+	}
+
+	relabeled, keep := c.relabel(container, labels)
+	if !keep {
+		return false
+	}
+
+	container.Labels = c.filterLabels(relabeled, labels)
+	return true
+}
+
+// relabel applies the relabeling rules to the labels and the metadata of the container,
+// and returns false if the container should be dropped
+func (c *Client) relabel(container *model.Container, labels map[string]string) (map[string]string, bool) {
+	rules := c.getRelabelRules()
+	if len(rules) == 0 {
+		return labels, true
+	}
+
+	input := map[string]string{
+		relabel.ContainerName:  container.Name,
+		relabel.ContainerImage: container.Image,
+		relabel.ContainerID:    container.Id,
+	}
+
+	for name, value := range labels {
+		input[name] = value
+	}
+
+	return relabel.Process(rules, input)
+}
+
+// filterLabels keeps the labels matching the label filters, and the ones
+// created or changed by the relabeling rules, compared to the Docker labels
+func (c *Client) filterLabels(labels, dockerLabels map[string]string) map[string]string {
+	labelFilters := c.getLabelFilters()
+
 	// return all labels if there aren't any filters
-	if len(c.labelFilters) == 1 && c.labelFilters[0] == "" {
-		return dc.Labels
+	if len(labelFilters) == 1 && labelFilters[0] == "" {
+		return labels
 	}
 
 	filteredLabels := map[string]string{}
 
-	for name, value := range dc.Labels {
-		for _, filter := range c.labelFilters {
+	for name, value := range labels {
+		if original, exists := dockerLabels[name]; !exists || original != value {
+			filteredLabels[name] = value
+			continue
+		}
+
+		for _, filter := range labelFilters {
 			// ignore case, match from the start
 			if matched, err := regexp.MatchString("(?i)^"+filter, name); err == nil && matched {
 				filteredLabels[name] = value
@@ -121,7 +299,7 @@ func (c *Client) getLabelsFor(dc dockerTypes.Container) map[string]string {
 }
 
 func (c *Client) GetStats(container *model.Container) (*model.Stats, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.getTimeout())
 	defer cancel()
 
 	response, err := c.client.ContainerStats(ctx, container.Id, false)
@@ -145,7 +323,18 @@ func (c *Client) ListenForEvents(channel chan<- []model.Container) {
 	for {
 		select {
 		case message := <-messages:
-			if message.Status == "start" || message.Status == "destroy" {
+			if handler := c.getEventHandler(); handler != nil && message.Type == events.ContainerEventType {
+				event := convertEvent(&message)
+
+				// only the events of the containers selected for the metrics are handled
+				if c.selectContainer(c.getContainerFilter(), &event.Container, eventLabels(&message)) {
+					handler(event)
+				}
+			}
+
+			// the health check status is parsed from the container list too
+			if message.Status == "start" || message.Status == "destroy" ||
+				strings.HasPrefix(message.Status, "health_status") {
 				waitFor := make(chan interface{})
 
 				func() {
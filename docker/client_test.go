@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/rycus86/container-metrics/model"
+	"github.com/rycus86/container-metrics/relabel"
+)
+
+func TestParseImageReference(t *testing.T) {
+	for reference, expected := range map[string][3]string{
+		"nginx":                            {"nginx", "latest", ""},
+		"nginx:1.15-alpine":                {"nginx", "1.15-alpine", ""},
+		"registry:5000/team/app":           {"registry:5000/team/app", "latest", ""},
+		"registry:5000/team/app:v2":        {"registry:5000/team/app", "v2", ""},
+		"rycus86/cntm@sha256:0123abcd":     {"rycus86/cntm", "", "sha256:0123abcd"},
+		"rycus86/cntm:1.0@sha256:0123abcd": {"rycus86/cntm", "1.0", "sha256:0123abcd"},
+		"sha256:4567ef":                    {"", "", ""},
+	} {
+		repository, tag, digest := parseImageReference(reference)
+
+		if actual := [3]string{repository, tag, digest}; actual != expected {
+			t.Errorf("Unexpected result for %s: %v", reference, actual)
+		}
+	}
+}
+
+func TestGetContainerHealth(t *testing.T) {
+	for status, expected := range map[string]string{
+		"Up 2 hours":                      "",
+		"Up 2 hours (healthy)":            "healthy",
+		"Up 1 second (unhealthy)":         "unhealthy",
+		"Up 5 seconds (health: starting)": "starting",
+	} {
+		if actual := getContainerHealth(dockerTypes.Container{Status: status}); actual != expected {
+			t.Errorf("Unexpected health for %s: %s", status, actual)
+		}
+	}
+}
+
+func TestSelectEventContainer(t *testing.T) {
+	filter, err := NewContainerFilter(nil, []string{"label=com.example.ci"}, false, nil)
+	if err != nil {
+		t.Fatal("Failed to create the filter:", err)
+	}
+
+	rules, err := relabel.Compile([]relabel.Config{
+		{Action: "drop", SourceLabels: []string{relabel.ContainerName}, Regex: "buildkit.*"},
+	}, nil)
+	if err != nil {
+		t.Fatal("Failed to compile the rules:", err)
+	}
+
+	c := &Client{labelFilters: []string{""}, filter: filter, relabelRules: rules}
+
+	for name, expected := range map[string]bool{"web": true, "ci-runner": false, "buildkit-1": false} {
+		message := &events.Message{
+			Type:   events.ContainerEventType,
+			Action: "die",
+			Actor: events.Actor{ID: "abcd", Attributes: map[string]string{
+				"name": name, "image": "app", "exitCode": "1",
+			}},
+		}
+
+		if name == "ci-runner" {
+			message.Actor.Attributes["com.example.ci"] = "true"
+		}
+
+		event := convertEvent(message)
+
+		if selected := c.selectContainer(c.filter, &event.Container, eventLabels(message)); selected != expected {
+			t.Errorf("Unexpected selection of %s: %v", name, selected)
+		}
+
+		if _, exists := event.Container.Labels["exitCode"]; exists {
+			t.Error("Unexpected event attribute in the labels:", event.Container.Labels)
+		}
+	}
+}
+
+func TestLabelFiltersKeepRelabeledLabels(t *testing.T) {
+	rules, err := relabel.Compile([]relabel.Config{
+		{SourceLabels: []string{"com.docker.compose.service"}, TargetLabel: "service"},
+		{SourceLabels: []string{relabel.ContainerImage}, Regex: ".*:(.*)", TargetLabel: "image_tag"},
+	}, nil)
+	if err != nil {
+		t.Fatal("Failed to compile the rules:", err)
+	}
+
+	c := &Client{labelFilters: []string{"com.example"}, relabelRules: rules}
+	container := &model.Container{Id: "abcd", Name: "web", Image: "nginx:1.15"}
+
+	c.selectContainer(nil, container, map[string]string{
+		"com.docker.compose.service": "frontend",
+		"com.example.team":           "ops",
+		"other":                      "x",
+	})
+
+	expected := map[string]string{"service": "frontend", "image_tag": "1.15", "com.example.team": "ops"}
+	if !reflect.DeepEqual(container.Labels, expected) {
+		t.Errorf("Unexpected labels: %v", container.Labels)
+	}
+}
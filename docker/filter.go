@@ -0,0 +1,208 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// EnableLabel is the container label to opt out of (or in to) the collection
+const EnableLabel = "cntm.enable"
+
+// ContainerFilter selects the containers to collect the metrics of
+type ContainerFilter struct {
+	include []containerMatcher
+	exclude []containerMatcher
+	optIn   bool
+
+	dockerFilters filters.Args
+}
+
+// containerMatcher matches the name, image or ID of the container,
+// or the presence or the value of a label
+type containerMatcher struct {
+	field string
+	label string
+	value *regexp.Regexp
+}
+
+// NewContainerFilter parses the include and exclude patterns, like `name=buildkit*`,
+// `image=/^gitlab\/.*runner/`, `label=com.example.ci` or `label=com.example.ci=true`,
+// and the Docker filters, like `label=com.example.monitored` or `network=backend`
+func NewContainerFilter(include, exclude []string, optIn bool, dockerFilters []string) (*ContainerFilter, error) {
+	f := &ContainerFilter{optIn: optIn, dockerFilters: filters.NewArgs()}
+
+	for _, pattern := range include {
+		m, err := parseContainerMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		f.include = append(f.include, m)
+	}
+
+	for _, pattern := range exclude {
+		m, err := parseContainerMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		f.exclude = append(f.exclude, m)
+	}
+
+	for _, item := range dockerFilters {
+		args, err := filters.ParseFlag(item, f.dockerFilters)
+		if err != nil {
+			return nil, err
+		}
+
+		f.dockerFilters = args
+	}
+
+	return f, nil
+}
+
+// SplitPatterns splits the comma separated container patterns,
+// except for the commas in the regular expressions between slashes
+func SplitPatterns(value string) []string {
+	var patterns []string
+
+	add := func(pattern string) {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	start, inRegex := 0, false
+
+	for idx := 0; idx < len(value); idx++ {
+		switch value[idx] {
+		case '/':
+			if !inRegex {
+				inRegex = idx > 0 && value[idx-1] == '='
+			} else if rest := strings.TrimLeft(value[idx+1:], " "); rest == "" || rest[0] == ',' {
+				inRegex = false
+			}
+
+		case ',':
+			if !inRegex {
+				add(value[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	add(value[start:])
+
+	return patterns
+}
+
+func parseContainerMatcher(pattern string) (containerMatcher, error) {
+	parts := strings.SplitN(pattern, "=", 2)
+	if len(parts) != 2 {
+		return containerMatcher{}, fmt.Errorf("invalid container pattern: %s", pattern)
+	}
+
+	m := containerMatcher{field: parts[0]}
+	value := parts[1]
+
+	switch m.field {
+	case "name", "image", "id":
+	case "label":
+		labelParts := strings.SplitN(value, "=", 2)
+
+		m.label = labelParts[0]
+		if len(labelParts) == 1 {
+			// only the presence of the label is checked
+			return m, nil
+		}
+
+		value = labelParts[1]
+	default:
+		return containerMatcher{}, fmt.Errorf("unknown field in container pattern: %s", pattern)
+	}
+
+	regex, err := compilePattern(value)
+	if err != nil {
+		return containerMatcher{}, fmt.Errorf("invalid container pattern: %s: %s", pattern, err)
+	}
+
+	m.value = regex
+	return m, nil
+}
+
+// compilePattern compiles regular expressions between slashes as they are,
+// and turns the other patterns into globs matching the whole value
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.Replace(expression, `\*`, ".*", -1)
+	expression = strings.Replace(expression, `\?`, ".", -1)
+
+	return regexp.Compile("^" + expression + "$")
+}
+
+func (m *containerMatcher) matches(c *model.Container, labels map[string]string) bool {
+	var value string
+
+	switch m.field {
+	case "name":
+		value = c.Name
+	case "image":
+		value = c.Image
+	case "id":
+		value = c.Id
+	case "label":
+		labelValue, exists := labels[m.label]
+		if !exists {
+			return false
+		}
+
+		if m.value == nil {
+			return true
+		}
+
+		value = labelValue
+	}
+
+	return m.value.MatchString(value)
+}
+
+// Matches returns true if the metrics of the container should be collected
+func (f *ContainerFilter) Matches(c *model.Container, labels map[string]string) bool {
+	if value, exists := labels[EnableLabel]; exists {
+		if enabled, err := strconv.ParseBool(value); err == nil && !enabled {
+			return false
+		} else if f.optIn && !enabled {
+			return false
+		}
+	} else if f.optIn {
+		return false
+	}
+
+	for _, m := range f.exclude {
+		if m.matches(c, labels) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+
+	for _, m := range f.include {
+		if m.matches(c, labels) {
+			return true
+		}
+	}
+
+	return false
+}
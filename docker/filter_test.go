@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestContainerFilter(t *testing.T) {
+	filter, err := NewContainerFilter(
+		[]string{"name=web-*", "image=/^nginx(:.*)?$/", "label=com.example.team=ops"},
+		[]string{"name=web-canary", "label=com.example.ci"},
+		false, nil)
+	if err != nil {
+		t.Fatal("Failed to create the filter:", err)
+	}
+
+	for _, item := range []struct {
+		name     string
+		image    string
+		labels   map[string]string
+		expected bool
+	}{
+		{"web-1", "app:1.0", nil, true},
+		{"web-canary", "app:1.0", nil, false},
+		{"proxy", "nginx:1.15", nil, true},
+		{"proxy", "nginx-exporter", nil, false},
+		{"db", "postgres", map[string]string{"com.example.team": "ops"}, true},
+		{"db", "postgres", map[string]string{"com.example.team": "dev"}, false},
+		{"web-2", "app:1.0", map[string]string{"com.example.ci": ""}, false},
+		{"web-3", "app:1.0", map[string]string{EnableLabel: "false"}, false},
+	} {
+		container := &model.Container{Name: item.name, Image: item.image}
+
+		if filter.Matches(container, item.labels) != item.expected {
+			t.Errorf("Unexpected result for %s (%s) %v", item.name, item.image, item.labels)
+		}
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	patterns := SplitPatterns(`name=/^app-[0-9]{1,3}$/, image=/^gitlab\/.*runner/,label=com.example.ci,,id=/[a,b]/`)
+
+	expected := []string{`name=/^app-[0-9]{1,3}$/`, `image=/^gitlab\/.*runner/`, `label=com.example.ci`, `id=/[a,b]/`}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Fatalf("Unexpected patterns: %q", patterns)
+	}
+
+	filter, err := NewContainerFilter(SplitPatterns("name=/^app-[0-9]{1,3}$/"), nil, false, nil)
+	if err != nil {
+		t.Fatal("Failed to create the filter:", err)
+	}
+
+	for name, expected := range map[string]bool{"app-1": true, "app-123": true, "app-1234": false, "app-": false} {
+		if filter.Matches(&model.Container{Name: name}, nil) != expected {
+			t.Errorf("Unexpected result for %s", name)
+		}
+	}
+}
+
+func TestContainerFilterOptIn(t *testing.T) {
+	filter, err := NewContainerFilter(nil, nil, true, []string{"label=com.example.monitored"})
+	if err != nil {
+		t.Fatal("Failed to create the filter:", err)
+	}
+
+	container := &model.Container{Name: "web"}
+
+	if filter.Matches(container, nil) {
+		t.Error("Expected to skip the container without the label")
+	}
+	if filter.Matches(container, map[string]string{EnableLabel: "no"}) {
+		t.Error("Expected to skip the container with an invalid label")
+	}
+	if !filter.Matches(container, map[string]string{EnableLabel: "true"}) {
+		t.Error("Expected to monitor the container with the label")
+	}
+
+	if !filter.dockerFilters.ExactMatch("label", "com.example.monitored") {
+		t.Error("Unexpected Docker filters:", filter.dockerFilters)
+	}
+}
+
+func TestInvalidContainerFilter(t *testing.T) {
+	for _, pattern := range []string{"web", "status=running", "name=/[a-/"} {
+		if _, err := NewContainerFilter([]string{pattern}, nil, false, nil); err == nil {
+			t.Errorf("Expected an error for %q", pattern)
+		}
+	}
+
+	if _, err := NewContainerFilter(nil, nil, false, []string{"label"}); err == nil {
+		t.Error("Expected an error for the invalid Docker filter")
+	}
+}
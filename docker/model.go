@@ -2,8 +2,10 @@ package docker
 
 import (
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 
 	"github.com/rycus86/container-metrics/model"
 )
@@ -12,6 +14,7 @@ func convertStats(d *types.StatsJSON, osType string) *model.Stats {
 	s := model.Stats{
 		Id:   d.ID,
 		Name: d.Name[1:],
+		Read: d.Read,
 
 		CpuStats: model.CpuStats{
 			Total:   d.CPUStats.CPUUsage.TotalUsage,
@@ -42,6 +45,11 @@ func convertStats(d *types.StatsJSON, osType string) *model.Stats {
 			TxDropped: 0,
 			TxErrors:  0,
 		},
+
+		PidsStats: model.PidsStats{
+			Current: d.PidsStats.Current,
+			Limit:   d.PidsStats.Limit,
+		},
 	}
 
 	for _, ioEntry := range d.BlkioStats.IoServiceBytesRecursive {
@@ -68,6 +76,44 @@ func convertStats(d *types.StatsJSON, osType string) *model.Stats {
 	return &s
 }
 
+func convertEvent(m *events.Message) *model.Event {
+	image := m.Actor.Attributes["image"]
+
+	// strip the hash after the @ if present
+	if atIndex := strings.Index(image, "@"); atIndex >= 0 {
+		image = image[0:atIndex]
+	}
+
+	return &model.Event{
+		Time:   time.Unix(0, m.TimeNano),
+		Action: m.Action,
+		Container: model.Container{
+			Id:    m.Actor.ID,
+			Name:  m.Actor.Attributes["name"],
+			Image: image,
+		},
+	}
+}
+
+// eventAttributes are the attributes of the container events that are not container labels
+var eventAttributes = map[string]bool{
+	"name": true, "image": true, "exitCode": true, "signal": true,
+	"execID": true, "container": true, "oldName": true,
+}
+
+// eventLabels returns the container labels from the attributes of the event
+func eventLabels(m *events.Message) map[string]string {
+	labels := map[string]string{}
+
+	for name, value := range m.Actor.Attributes {
+		if !eventAttributes[name] {
+			labels[name] = value
+		}
+	}
+
+	return labels
+}
+
 func calculateCPUPercent(v *types.StatsJSON, osType string) float64 {
 	if osType != "windows" {
 		previousCPU := v.PreCPUStats.CPUUsage.TotalUsage
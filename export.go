@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rycus86/container-metrics/config"
+	"github.com/rycus86/container-metrics/output"
+)
+
+// runExport writes the samples recorded in the local store
+// to the standard output or a file, for backfilling the gaps later
+func runExport(args []string) {
+	var (
+		directory string
+		since     time.Duration
+		format    string
+		target    string
+	)
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+
+	flags.StringVar(&directory, "store-dir", "",
+		"Directory the samples were recorded in")
+	flags.DurationVar(&since, "since", 24*time.Hour,
+		"Export the samples recorded in this time")
+	flags.StringVar(&format, "format", "openmetrics",
+		"Format to export the samples in (openmetrics or csv)")
+	flags.StringVar(&target, "output", "",
+		"File to write the samples to instead of the standard output")
+
+	if err := config.ApplyEnvironment(flags, environmentPrefix, os.LookupEnv); err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+
+	flags.Parse(args)
+
+	if directory == "" {
+		log.Fatalln("The -store-dir flag is required for the export")
+	}
+
+	var w io.Writer = os.Stdout
+
+	if target != "" {
+		file, err := os.Create(target)
+		if err != nil {
+			log.Fatalln("Failed to create the export file", err)
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	if err := output.Export(w, directory, time.Now().Add(-since), format); err != nil {
+		log.Fatalln("Failed to export the samples", err)
+	}
+}
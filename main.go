@@ -8,11 +8,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/rycus86/container-metrics/alert"
 	"github.com/rycus86/container-metrics/docker"
 	"github.com/rycus86/container-metrics/logging"
 	"github.com/rycus86/container-metrics/metrics"
 	"github.com/rycus86/container-metrics/model"
-	"strings"
+	"github.com/rycus86/container-metrics/output"
 )
 
 type MetricsCollector struct {
@@ -20,6 +21,12 @@ type MetricsCollector struct {
 	httpPort int
 	ticker   *time.Ticker
 	updates  chan []model.Container
+
+	onScrape bool
+	outputs  []output.Output
+	alerts   *alert.Engine
+
+	options *options
 }
 
 func (mc *MetricsCollector) Setup() {
@@ -36,6 +43,19 @@ func (mc *MetricsCollector) Setup() {
 		log.Println("Metrics ready")
 	}
 
+	if mc.onScrape {
+		log.Println("Collecting metrics on scrape")
+	}
+
+	for _, out := range mc.outputs {
+		metrics.AddListener(out)
+	}
+
+	if mc.alerts != nil {
+		metrics.AddListener(mc.alerts)
+		mc.client.SetEventHandler(mc.alerts.OnEvent)
+	}
+
 	go metrics.Serve(mc.httpPort)
 
 	go mc.client.ListenForEvents(mc.updates)
@@ -51,7 +71,13 @@ func (mc *MetricsCollector) Run() {
 
 	log.Println("Running ...")
 
-	go mc.recordMetrics()
+	var tick <-chan time.Time
+
+	if !mc.onScrape {
+		tick = mc.ticker.C
+
+		go mc.recordMetrics()
+	}
 
 	for {
 		select {
@@ -63,7 +89,7 @@ func (mc *MetricsCollector) Run() {
 
 			metrics.PrepareMetrics(containers)
 
-		case <-mc.ticker.C:
+		case <-tick:
 			if logging.IsVerboseEnabled() {
 				log.Println("Recording metrics")
 			}
@@ -71,11 +97,28 @@ func (mc *MetricsCollector) Run() {
 			go mc.recordMetrics()
 
 		case s := <-signals:
-			if s != syscall.SIGHUP {
-				mc.ticker.Stop()
-				log.Println("Exiting ...")
-				return
-			} // TODO SIGHUP
+			if s == syscall.SIGHUP {
+				log.Println("Reloading the configuration ...")
+
+				mc.reload()
+
+				if !mc.onScrape {
+					tick = mc.ticker.C
+				}
+
+				continue
+			}
+
+			mc.ticker.Stop()
+			log.Println("Exiting ...")
+
+			closeOutputs(mc.outputs)
+
+			if mc.alerts != nil {
+				mc.alerts.Close()
+			}
+
+			return
 		}
 	}
 }
@@ -111,62 +154,71 @@ func (mc *MetricsCollector) statsFunc(c *model.Container) (*model.Stats, error)
 }
 
 func main() {
-	var (
-		port     int
-		interval time.Duration
-		timeout  time.Duration
-		labels   string
-		debug    bool
-		verbose  bool
-	)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
-	// -p or -port
-	flag.IntVar(&port, "port", 8080,
-		"HTTP port to listen on")
-	flag.IntVar(&port, "p", 8080,
-		"HTTP port to listen on (shorthand)")
-	// -i or -interval
-	flag.DurationVar(&interval, "interval", 5*time.Second,
-		"Interval for reading metrics from the engine")
-	flag.DurationVar(&interval, "i", 5*time.Second,
-		"Interval for reading metrics from the engine (shorthand)")
-	// -t or -timeout
-	flag.DurationVar(&timeout, "timeout", 30*time.Second,
-		"Timeout for calling endpoints on the engine")
-	flag.DurationVar(&timeout, "t", 30*time.Second,
-		"Timeout for calling endpoints on the engine (shorthand)")
-	// -l or -labels
-	flag.StringVar(&labels, "labels", "",
-		"Labels to keep (comma separated, accepts regex)")
-	flag.StringVar(&labels, "l", "",
-		"Labels to keep (comma separated, accepts regex) (shorthand)")
-	// -d or -debug
-	flag.BoolVar(&debug, "debug", false,
-		"Enable debug messages")
-	flag.BoolVar(&debug, "d", false,
-		"Enable debug messages (shorthand)")
-	// -v or -verbose
-	flag.BoolVar(&verbose, "verbose", false,
-		"Enable verbose messages - assumes debug")
-	flag.BoolVar(&verbose, "v", false,
-		"Enable verbose messages - assumes debug (shorthand)")
-
-	flag.Parse()
-
-	logging.Setup(debug, verbose)
-
-	dockerClient, err := docker.NewClient(timeout, strings.Split(labels, ","))
+	opts, err := loadOptions(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+
+	logging.Setup(opts.debug, opts.verbose)
+
+	dockerClient, err := docker.NewClient(opts.dockerHost, opts.timeout, opts.labelFilters())
 	if err != nil {
 		log.Panicln("Failed to connect to the Docker daemon", err)
 	}
 
+	dockerClient.SetRelabelRules(opts.relabelRules)
+	dockerClient.SetContainerFilter(opts.filter)
+
+	outputs, err := opts.outputs.create(opts.interval, opts.timeout)
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	var alerts *alert.Engine
+
+	if opts.alertConfig != nil {
+		if alerts, err = alert.NewEngine(*opts.alertConfig); err != nil {
+			log.Panicln(err)
+		}
+	}
+
 	collector := &MetricsCollector{
 		client:   dockerClient,
-		httpPort: port,
-		ticker:   time.NewTicker(interval),
+		httpPort: opts.port,
+		ticker:   time.NewTicker(opts.interval),
 		updates:  make(chan []model.Container),
+
+		onScrape: opts.onScrape,
+		outputs:  outputs,
+		alerts:   alerts,
+
+		options: opts,
+	}
+
+	metrics.SetNaming(opts.namespace, opts.naming)
+	metrics.SetMetricNames(parseKeyValues(opts.metricNames))
+	metrics.SetInfoLabels(opts.infoLabels)
+	metrics.SetLimits(opts.limits())
+
+	if err := metrics.SetEnabledCollectors(opts.collectors); err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+
+	metrics.SetTimestamps(opts.timestamps)
+	metrics.SetMaxStreamClients(opts.streamClients)
+	metrics.SetHistory(opts.history, opts.historySize())
+
+	if opts.onScrape {
+		metrics.CollectOnScrape(collector.statsFunc, dockerClient.GetEngineStats, opts.scrapeCache, opts.timeout)
 	}
 
 	collector.Setup()
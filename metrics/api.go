@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+const apiPrefix = "/api/v1/"
+
+type containerResponse struct {
+	Container model.Container `json:"container"`
+	Stats     *model.Stats    `json:"stats"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// containerFilter selects containers by a name pattern
+// and label presence (name) or value (name=value)
+type containerFilter struct {
+	name   *regexp.Regexp
+	labels []string
+}
+
+func parseContainerFilter(r *http.Request) (*containerFilter, error) {
+	filter := &containerFilter{}
+
+	if pattern := r.URL.Query().Get("name"); pattern != "" {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.name = compiled
+	}
+
+	filter.labels = r.URL.Query()["label"]
+
+	return filter, nil
+}
+
+func (f *containerFilter) matches(c *model.Container) bool {
+	if f.name != nil && !f.name.MatchString(c.Name) {
+		return false
+	}
+
+	for _, label := range f.labels {
+		parts := strings.SplitN(label, "=", 2)
+
+		value, exists := c.Labels[parts[0]]
+		if !exists {
+			return false
+		}
+
+		if len(parts) == 2 && value != parts[1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func serveContainers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseContainerFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"Invalid filter: " + err.Error()})
+		return
+	}
+
+	response := []containerResponse{}
+
+	if current := getCurrent(); current != nil {
+		for _, c := range current.Containers {
+			if filter.matches(&c) {
+				response = append(response, containerResponse{Container: c, Stats: getCached(c.Id)})
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type historyResponse struct {
+	Container model.Container `json:"container"`
+	History   []*model.Stats  `json:"history"`
+}
+
+func serveContainer(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, apiPrefix+"containers/")
+
+	history := strings.HasSuffix(id, "/history")
+	if history {
+		id = strings.TrimSuffix(id, "/history")
+	}
+
+	c := findContainer(id)
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{"Container not found: " + id})
+		return
+	}
+
+	if history {
+		writeJSON(w, http.StatusOK, historyResponse{Container: *c, History: getHistory(c.Id)})
+	} else {
+		writeJSON(w, http.StatusOK, containerResponse{Container: *c, Stats: getCached(c.Id)})
+	}
+}
+
+func findContainer(id string) *model.Container {
+	if current := getCurrent(); current != nil && id != "" {
+		for _, c := range current.Containers {
+			// accept full and short IDs and names too, like the Docker CLI
+			if c.Id == id || c.Name == id || (len(id) >= 12 && strings.HasPrefix(c.Id, id)) {
+				return &c
+			}
+		}
+	}
+
+	return nil
+}
+
+func serveEngine(w http.ResponseWriter, r *http.Request) {
+	if current := getCurrent(); current != nil {
+		if engineStats := current.getEngineStats(); engineStats != nil {
+			writeJSON(w, http.StatusOK, engineStats)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusServiceUnavailable, errorResponse{"Engine stats are not available yet"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+}
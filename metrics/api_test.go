@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestContainersAPI(t *testing.T) {
+	setCurrent(NewMetrics([]model.Container{
+		{Id: "0123456789abcdef", Name: "web", Labels: map[string]string{"tier": "front"}},
+		{Id: "fedcba9876543210", Name: "db", Labels: map[string]string{"tier": "back"}},
+	}))
+
+	cacheStats("0123456789abcdef", &model.Stats{Name: "web", CpuStats: model.CpuStats{Percent: 1.5}})
+
+	var listed []containerResponse
+
+	recorder := httptest.NewRecorder()
+	serveContainers(recorder, httptest.NewRequest("GET", "/api/v1/containers?label=tier=front", nil))
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &listed); err != nil {
+		t.Fatal("Failed to decode the response:", err)
+	}
+
+	if len(listed) != 1 || listed[0].Container.Name != "web" || listed[0].Stats.CpuStats.Percent != 1.5 {
+		t.Error("Unexpected containers:", listed)
+	}
+
+	recorder = httptest.NewRecorder()
+	serveContainers(recorder, httptest.NewRequest("GET", "/api/v1/containers?name=^d", nil))
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &listed); err != nil {
+		t.Fatal("Failed to decode the response:", err)
+	}
+
+	if len(listed) != 1 || listed[0].Container.Name != "db" || listed[0].Stats != nil {
+		t.Error("Unexpected containers:", listed)
+	}
+
+	recorder = httptest.NewRecorder()
+	serveContainer(recorder, httptest.NewRequest("GET", "/api/v1/containers/0123456789ab", nil))
+
+	var single containerResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &single); err != nil {
+		t.Fatal("Failed to decode the response:", err)
+	}
+
+	if single.Container.Name != "web" {
+		t.Error("Unexpected container:", single)
+	}
+
+	recorder = httptest.NewRecorder()
+	serveContainer(recorder, httptest.NewRequest("GET", "/api/v1/containers/missing", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Error("Unexpected status:", recorder.Code)
+	}
+}
+
+func TestEngineAPI(t *testing.T) {
+	setCurrent(NewMetrics(nil))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for idx := 0; idx < 100; idx++ {
+			RecordEngineStats(&model.EngineStats{Host: "pi-01"})
+		}
+	}()
+
+	// the engine stats are read while they are recorded, run with -race
+	for idx := 0; idx < 100; idx++ {
+		serveEngine(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/engine", nil))
+	}
+
+	<-done
+
+	recorder := httptest.NewRecorder()
+	serveEngine(recorder, httptest.NewRequest("GET", "/api/v1/engine", nil))
+
+	var engine model.EngineStats
+	if err := json.Unmarshal(recorder.Body.Bytes(), &engine); err != nil || engine.Host != "pi-01" {
+		t.Error("Unexpected engine stats:", recorder.Body.String())
+	}
+}
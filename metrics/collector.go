@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Collector adds a group of related metric families,
+// that can be enabled or disabled together
+type Collector interface {
+	AddMetrics(pm *PrometheusMetrics, labelNames []string)
+}
+
+// CollectorFunc adapts a function to the Collector interface
+type CollectorFunc func(pm *PrometheusMetrics, labelNames []string)
+
+func (f CollectorFunc) AddMetrics(pm *PrometheusMetrics, labelNames []string) {
+	f(pm, labelNames)
+}
+
+// CollectorInfo describes a registered collector
+type CollectorInfo struct {
+	Name             string
+	Description      string
+	EnabledByDefault bool
+
+	collector Collector
+}
+
+var (
+	collectors        = map[string]*CollectorInfo{}
+	enabledCollectors = map[string]bool{}
+	collectorsLock    sync.Mutex
+)
+
+// RegisterCollector adds a collector to the registry, it is meant to be called
+// from the init functions, before the command line flags are set up
+func RegisterCollector(name, description string, enabledByDefault bool, collector Collector) {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	if _, exists := collectors[name]; exists {
+		panic("collector already registered: " + name)
+	}
+
+	collectors[name] = &CollectorInfo{
+		Name:             name,
+		Description:      description,
+		EnabledByDefault: enabledByDefault,
+
+		collector: collector,
+	}
+}
+
+// unregisterCollector removes a collector from the registry, for the tests
+func unregisterCollector(name string) {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	delete(collectors, name)
+	delete(enabledCollectors, name)
+}
+
+// Collectors returns the registered collectors ordered by name
+func Collectors() []CollectorInfo {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	return sortedCollectors()
+}
+
+func sortedCollectors() []CollectorInfo {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	result := make([]CollectorInfo, len(names))
+	for idx, name := range names {
+		result[idx] = *collectors[name]
+	}
+
+	return result
+}
+
+// SetEnabledCollectors enables or disables the collectors in the map,
+// and resets the others to their defaults
+func SetEnabledCollectors(enabled map[string]bool) error {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	for name := range enabled {
+		if _, exists := collectors[name]; !exists {
+			return fmt.Errorf("unknown collector: %s", name)
+		}
+	}
+
+	enabledCollectors = map[string]bool{}
+	for name, value := range enabled {
+		enabledCollectors[name] = value
+	}
+
+	return nil
+}
+
+func isCollectorEnabled(info *CollectorInfo) bool {
+	if enabled, exists := enabledCollectors[info.Name]; exists {
+		return enabled
+	}
+
+	return info.EnabledByDefault
+}
+
+func addEnabledMetrics(pm *PrometheusMetrics) {
+	collectorsLock.Lock()
+	enabled := make([]CollectorInfo, 0, len(collectors))
+	for _, info := range sortedCollectors() {
+		if isCollectorEnabled(&info) {
+			enabled = append(enabled, info)
+		}
+	}
+	collectorsLock.Unlock()
+
+	labelNames := pm.GetLabelNames()
+
+	for _, info := range enabled {
+		info.collector.AddMetrics(pm, labelNames)
+	}
+}
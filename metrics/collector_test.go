@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestCollectors(t *testing.T) {
+	defer SetEnabledCollectors(nil)
+
+	RegisterCollector("test_extra", "Test metrics", false, CollectorFunc(
+		func(pm *PrometheusMetrics, labelNames []string) {
+			pm.Add(newGauge("test_extra_value", "Test value", labelNames,
+				func(s *model.Stats) float64 { return 42 }))
+		}))
+	defer unregisterCollector("test_extra")
+
+	names := describedNames(NewMetrics(nil))
+	for name, expected := range map[string]bool{
+		"cntm_engine_num_images": true, "cntm_cpu_usage_percent": true, "cntm_net_rx_bytes": true, "cntm_test_extra_value": false,
+	} {
+		if names[name] != expected {
+			t.Errorf("Unexpected presence of %s with the default collectors: %v", name, names[name])
+		}
+	}
+
+	if err := SetEnabledCollectors(map[string]bool{"test_extra": true, "engine": false, "network": false}); err != nil {
+		t.Fatal("Failed to set the collectors:", err)
+	}
+
+	names = describedNames(NewMetrics(nil))
+	for name, expected := range map[string]bool{
+		"cntm_engine_num_images": false, "cntm_cpu_usage_percent": true, "cntm_net_rx_bytes": false, "cntm_test_extra_value": true,
+	} {
+		if names[name] != expected {
+			t.Errorf("Unexpected presence of %s with the changed collectors: %v", name, names[name])
+		}
+	}
+
+	if err := SetEnabledCollectors(map[string]bool{"unknown": true}); err == nil {
+		t.Error("Expected an error for an unknown collector")
+	}
+}
+
+var describedName = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// describedNames returns the names of the metric families described by the metrics
+func describedNames(pm *PrometheusMetrics) map[string]bool {
+	ch := make(chan *prometheus.Desc, 100)
+
+	for _, metric := range pm.Metrics {
+		metric.Describe(ch)
+	}
+	for _, metric := range pm.EngineMetrics {
+		metric.Describe(ch)
+	}
+
+	close(ch)
+
+	names := map[string]bool{}
+	for desc := range ch {
+		if match := describedName.FindStringSubmatch(desc.String()); match != nil {
+			names[match[1]] = true
+		}
+	}
+
+	return names
+}
+
+func TestHealthMetric(t *testing.T) {
+	pm := NewMetrics(nil)
+	metric := newHealthGauge("health_status", "Health", pm.GetLabelNames()).WithParent(pm)
+
+	metric.Set(&model.Container{Name: "no-healthcheck"}, &model.Stats{})
+	metric.Set(&model.Container{Name: "web", Health: model.Healthy}, &model.Stats{})
+	metric.Set(&model.Container{Name: "db", Health: model.Unhealthy}, &model.Stats{})
+
+	ch := make(chan prometheus.Metric, 10)
+	metric.Collect(ch)
+	close(ch)
+
+	if len(ch) != 2 {
+		t.Errorf("Unexpected number of samples: %d", len(ch))
+	}
+}
+
+func TestContainerInfoMetric(t *testing.T) {
+	pm := NewMetrics([]model.Container{{
+		Id: "abcdef", Name: "web", Image: "nginx:1.15",
+		ImageRepository: "nginx", ImageTag: "1.15", ImageID: "sha256:0123",
+	}})
+	pm.setEngineStats(&model.EngineStats{Host: "pi-01"})
+
+	metric := newContainerInfo("container_info", "Info").WithParent(pm)
+
+	ch := make(chan prometheus.Metric, 10)
+	metric.Collect(ch)
+	close(ch)
+
+	if len(ch) != 1 {
+		t.Fatalf("Unexpected number of samples: %d", len(ch))
+	}
+
+	out := &dto.Metric{}
+	(<-ch).Write(out)
+
+	labels := map[string]string{}
+	for _, pair := range out.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+
+	if out.Gauge.GetValue() != 1 || labels["container_id"] != "abcdef" || labels["engine_host"] != "pi-01" ||
+		labels["image_repository"] != "nginx" || labels["image_tag"] != "1.15" || labels["image_id"] != "sha256:0123" {
+		t.Errorf("Unexpected sample: %v", out)
+	}
+}
+
+func TestInfoLabels(t *testing.T) {
+	SetInfoLabels(true)
+	defer SetInfoLabels(false)
+
+	c := model.Container{Id: "abcdef", Name: "web", Image: "nginx", Labels: map[string]string{
+		"com.example.team": "ops", "image_tag": "conflicting",
+	}}
+
+	pm := NewMetrics([]model.Container{c})
+	pm.setEngineStats(&model.EngineStats{Host: "pi-01"})
+
+	expected := map[string]string{"container_id": "abcdef", "container_name": "web", "engine_host": "pi-01"}
+	if labels := pm.extractLabels(&c); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Unexpected labels on the value metrics: %v", labels)
+	}
+
+	metric := newContainerInfo("container_info", "Info").WithParent(pm)
+
+	ch := make(chan prometheus.Metric, 10)
+	metric.Collect(ch)
+	close(ch)
+
+	out := &dto.Metric{}
+	(<-ch).Write(out)
+
+	labels := map[string]string{}
+	for _, pair := range out.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+
+	if len(labels) != 9 || labels["com_example_team"] != "ops" || labels["container_image"] != "nginx" || labels["image_tag"] != "" {
+		t.Errorf("Unexpected labels on the info metric: %v", labels)
+	}
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func init() {
+	RegisterCollector("engine", "Number of images and containers on the engine", true, CollectorFunc(addEngineMetrics))
+	RegisterCollector("stats", "Age of the container stats", true, CollectorFunc(addStatsMetrics))
+	RegisterCollector("cpu", "CPU usage of the containers", true, CollectorFunc(addCpuMetrics))
+	RegisterCollector("memory", "Memory usage of the containers", true, CollectorFunc(addMemoryMetrics))
+	RegisterCollector("io", "Block I/O of the containers", true, CollectorFunc(addIOMetrics))
+	RegisterCollector("network", "Network traffic of the containers", true, CollectorFunc(addNetworkMetrics))
+	RegisterCollector("pids", "Number of processes and threads in the containers", true, CollectorFunc(addPidsMetrics))
+	RegisterCollector("health", "Health check status of the containers", true, CollectorFunc(addHealthMetrics))
+	RegisterCollector("info", "Image details of the containers", true, CollectorFunc(addInfoMetrics))
+}
+
+func addEngineMetrics(metrics *PrometheusMetrics, _ []string) {
+	metrics.AddEngine(newEngineGauge(
+		"engine_num_images", "Number of images",
+		func(stats *model.EngineStats) float64 {
+			return float64(stats.Images)
+		},
+	))
+	metrics.AddEngine(newEngineGauge(
+		"engine_num_containers", "Number of containers",
+		func(stats *model.EngineStats) float64 {
+			return float64(stats.Containers)
+		},
+	))
+	metrics.AddEngine(newEngineGauge(
+		"engine_num_containers_running", "Number of running containers",
+		func(stats *model.EngineStats) float64 {
+			return float64(stats.ContainersRunning)
+		},
+	))
+	metrics.AddEngine(newEngineGauge(
+		"engine_num_containers_stopped", "Number of stopped containers",
+		func(stats *model.EngineStats) float64 {
+			return float64(stats.ContainersStopped)
+		},
+	))
+	metrics.AddEngine(newEngineGauge(
+		"engine_num_containers_paused", "Number of paused containers",
+		func(stats *model.EngineStats) float64 {
+			return float64(stats.ContainersPaused)
+		},
+	))
+}
+
+func addStatsMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newStatsAge(
+		"stats_age_seconds", "Time since the stats were last read", baseLabels))
+}
+
+func addCpuMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newGauge(
+		"cpu_usage_total_seconds", "Total CPU usage", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.CpuStats.Total) / float64(time.Second)
+		}))
+	metrics.Add(newGauge(
+		"cpu_usage_system_seconds", "CPU usage in system mode", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.CpuStats.System) / float64(time.Second)
+		}))
+	metrics.Add(newGauge(
+		"cpu_usage_user_seconds", "CPU usage in user mode", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.CpuStats.User) / float64(time.Second)
+		}))
+	metrics.Add(newGauge(
+		"cpu_usage_percent", "Total CPU usage in percent", baseLabels,
+		func(s *model.Stats) float64 {
+			return s.CpuStats.Percent
+		}))
+}
+
+func addMemoryMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newGauge(
+		"memory_total_bytes", "Total memory available", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.MemoryStats.Total)
+		}))
+	metrics.Add(newGauge(
+		"memory_usage_bytes", "Memory usage", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.MemoryStats.Usage)
+		}))
+	metrics.Add(newGauge(
+		"memory_usage_percent", "Memory usage in percent", baseLabels,
+		func(s *model.Stats) float64 {
+			return s.MemoryStats.Percent
+		}))
+}
+
+func addIOMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newGauge(
+		"io_read_bytes", "I/O bytes read", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.IOStats.Read)
+		}))
+	metrics.Add(newGauge(
+		"io_write_bytes", "I/O bytes written", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.IOStats.Written)
+		}))
+}
+
+func addNetworkMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newGauge(
+		"net_rx_bytes", "Network receive bytes", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.RxBytes)
+		}))
+	metrics.Add(newGauge(
+		"net_rx_packets", "Network receive packets", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.RxPackets)
+		}))
+	metrics.Add(newGauge(
+		"net_rx_dropped", "Network receive packets dropped", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.RxDropped)
+		}))
+	metrics.Add(newGauge(
+		"net_rx_errors", "Network receive errors", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.RxErrors)
+		}))
+
+	metrics.Add(newGauge(
+		"net_tx_bytes", "Network transmit bytes", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.TxBytes)
+		}))
+	metrics.Add(newGauge(
+		"net_tx_packets", "Network transmit packets", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.TxPackets)
+		}))
+	metrics.Add(newGauge(
+		"net_tx_dropped", "Network transmit packets dropped", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.TxDropped)
+		}))
+	metrics.Add(newGauge(
+		"net_tx_errors", "Network transmit errors", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.NetworkStats.TxErrors)
+		}))
+}
+
+func addPidsMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newGauge(
+		"pids_current", "Number of processes and threads", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.PidsStats.Current)
+		}))
+	metrics.Add(newGauge(
+		"pids_limit", "Maximum number of processes and threads, 0 if unlimited", baseLabels,
+		func(s *model.Stats) float64 {
+			return float64(s.PidsStats.Limit)
+		}))
+}
+
+func addHealthMetrics(metrics *PrometheusMetrics, baseLabels []string) {
+	metrics.Add(newHealthGauge(
+		"health_status", "Health check status, 1 if healthy, 0 if starting or unhealthy", baseLabels))
+}
+
+func addInfoMetrics(metrics *PrometheusMetrics, _ []string) {
+	metrics.Add(newContainerInfo(
+		"container_info", "Information about the container and its image, always 1"))
+}
@@ -2,7 +2,6 @@ package metrics
 
 import (
 	"regexp"
-	"time"
 
 	"github.com/rycus86/container-metrics/model"
 )
@@ -11,160 +10,49 @@ var (
 	nonLettersOrDigits = regexp.MustCompile("[^A-Za-z0-9_]")
 )
 
+// SanitizeName replaces the characters not allowed in metric and label names
+func SanitizeName(name string) string {
+	return nonLettersOrDigits.ReplaceAllString(name, "_")
+}
+
 func NewMetrics(containers []model.Container) *PrometheusMetrics {
-	baseLabels := map[string]string{
-		"container.name":  "container_name",
-		"container.image": "container_image",
-		"engine.host":     "engine_host",
-	}
+	naming := baseLabelNames()
+	limits := getLimits()
+
+	baseLabels := naming.base
+	containerLabels := baseLabels
+	infoLabels := map[string]string{}
 
-	for _, c := range containers {
-		for labelName := range c.Labels {
-			normalizedName := nonLettersOrDigits.ReplaceAllString(labelName, "_")
-			baseLabels[labelName] = normalizedName
+	if infoLabelsEnabled() {
+		// the value metrics only identify the containers, the rest goes on the info metric
+		if _, exists := baseLabels["container.id"]; !exists {
+			baseLabels["container.id"] = "container_id"
 		}
+
+		delete(baseLabels, "container.image")
+		containerLabels = infoLabels
+	}
+
+	for _, labelName := range limitLabelKeys(containers, limits) {
+		containerLabels[labelName] = naming.labelPrefix + SanitizeName(labelName)
 	}
 
 	metrics := &PrometheusMetrics{
 		Containers: containers,
 		Labels:     baseLabels,
+		InfoLabels: infoLabels,
+		IDPrefix:   naming.idPrefix,
+
+		limits: limits,
 	}
 
-	addAllMetrics(metrics)
+	addEnabledMetrics(metrics)
+	limitSeries(metrics, limits)
+	countLongValues(metrics, limits)
 
 	if current := getCurrent(); current != nil {
-		recordEngineStatsOn(metrics, current.EngineStats)
+		recordEngineStatsOn(metrics, current.getEngineStats())
 	}
 
 	return metrics
 }
-
-func addAllMetrics(metrics *PrometheusMetrics) {
-	baseLabels := metrics.GetLabelNames()
-
-	// Engine metrics
-	metrics.AddEngine(newEngineGauge(
-		"engine_num_images", "Number of images",
-		func(stats *model.EngineStats) float64 {
-			return float64(stats.Images)
-		},
-	))
-	metrics.AddEngine(newEngineGauge(
-		"engine_num_containers", "Number of containers",
-		func(stats *model.EngineStats) float64 {
-			return float64(stats.Containers)
-		},
-	))
-	metrics.AddEngine(newEngineGauge(
-		"engine_num_containers_running", "Number of running containers",
-		func(stats *model.EngineStats) float64 {
-			return float64(stats.ContainersRunning)
-		},
-	))
-	metrics.AddEngine(newEngineGauge(
-		"engine_num_containers_stopped", "Number of stopped containers",
-		func(stats *model.EngineStats) float64 {
-			return float64(stats.ContainersStopped)
-		},
-	))
-	metrics.AddEngine(newEngineGauge(
-		"engine_num_containers_paused", "Number of paused containers",
-		func(stats *model.EngineStats) float64 {
-			return float64(stats.ContainersPaused)
-		},
-	))
-
-	// CPU metrics
-	metrics.Add(newGauge(
-		"cpu_usage_total_seconds", "Total CPU usage", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.CpuStats.Total) / float64(time.Second)
-		}))
-	metrics.Add(newGauge(
-		"cpu_usage_system_seconds", "CPU usage in system mode", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.CpuStats.System) / float64(time.Second)
-		}))
-	metrics.Add(newGauge(
-		"cpu_usage_user_seconds", "CPU usage in user mode", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.CpuStats.User) / float64(time.Second)
-		}))
-	metrics.Add(newGauge(
-		"cpu_usage_percent", "Total CPU usage in percent", baseLabels,
-		func(s *model.Stats) float64 {
-			return s.CpuStats.Percent
-		}))
-
-	// Memory metrics
-	metrics.Add(newGauge(
-		"memory_total_bytes", "Total memory available", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.MemoryStats.Total)
-		}))
-	metrics.Add(newGauge(
-		"memory_usage_bytes", "Memory usage", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.MemoryStats.Usage)
-		}))
-	metrics.Add(newGauge(
-		"memory_usage_percent", "Memory usage in percent", baseLabels,
-		func(s *model.Stats) float64 {
-			return s.MemoryStats.Percent
-		}))
-
-	// I/O metrics
-	metrics.Add(newGauge(
-		"io_read_bytes", "I/O bytes read", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.IOStats.Read)
-		}))
-	metrics.Add(newGauge(
-		"io_write_bytes", "I/O bytes written", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.IOStats.Written)
-		}))
-
-	// Network metrics
-	metrics.Add(newGauge(
-		"net_rx_bytes", "Network receive bytes", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.RxBytes)
-		}))
-	metrics.Add(newGauge(
-		"net_rx_packets", "Network receive packets", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.RxPackets)
-		}))
-	metrics.Add(newGauge(
-		"net_rx_dropped", "Network receive packets dropped", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.RxDropped)
-		}))
-	metrics.Add(newGauge(
-		"net_rx_errors", "Network receive errors", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.RxErrors)
-		}))
-
-	metrics.Add(newGauge(
-		"net_tx_bytes", "Network transmit bytes", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.TxBytes)
-		}))
-	metrics.Add(newGauge(
-		"net_tx_packets", "Network transmit packets", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.TxPackets)
-		}))
-	metrics.Add(newGauge(
-		"net_tx_dropped", "Network transmit packets dropped", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.TxDropped)
-		}))
-	metrics.Add(newGauge(
-		"net_tx_errors", "Network transmit errors", baseLabels,
-		func(s *model.Stats) float64 {
-			return float64(s.NetworkStats.TxErrors)
-		}))
-}
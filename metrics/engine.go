@@ -17,9 +17,8 @@ type EngineGaugeMetric struct {
 func newEngineGauge(name, help string, mapper EngineMapper) *EngineGaugeMetric {
 	return &EngineGaugeMetric{
 		Metric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: defaultNamespace,
-			Name:      name,
-			Help:      help,
+			Name: metricName(name),
+			Help: help,
 		}, []string{"engine_host"}),
 
 		Mapper: mapper,
@@ -12,18 +12,25 @@ type GaugeMetric struct {
 	Metric *prometheus.GaugeVec
 	Mapper Mapper
 
+	Desc       *prometheus.Desc
+	LabelNames []string
+
 	Parent *PrometheusMetrics
 }
 
 func newGauge(name, help string, baseLabels []string, mapper Mapper) *GaugeMetric {
 	return &GaugeMetric{
 		Metric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: defaultNamespace,
-			Name:      name,
-			Help:      help,
+			Name: metricName(name),
+			Help: help,
 		}, baseLabels),
 
 		Mapper: mapper,
+
+		Desc: prometheus.NewDesc(
+			metricName(name),
+			help, baseLabels, nil),
+		LabelNames: baseLabels,
 	}
 }
 
@@ -32,7 +39,34 @@ func (m *GaugeMetric) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (m *GaugeMetric) Collect(ch chan<- prometheus.Metric) {
-	m.Metric.Collect(ch)
+	if timestampsEnabled() {
+		m.collectWithTimestamps(ch)
+	} else {
+		m.Metric.Collect(ch)
+	}
+}
+
+// collectWithTimestamps builds the samples from the cached stats
+// with the time they were read at by the Docker daemon
+func (m *GaugeMetric) collectWithTimestamps(ch chan<- prometheus.Metric) {
+	for _, item := range m.Parent.Containers {
+		c := item
+
+		if m.Parent.hidden[c.Id] {
+			continue
+		}
+
+		s := getCached(c.Id)
+		if s == nil {
+			continue
+		}
+
+		labelValues := m.Parent.labelValues(&c, m.LabelNames)
+
+		if metric, err := newTimestampedGauge(m.Desc, m.Mapper(s), s.Read, labelValues); err == nil {
+			ch <- metric
+		}
+	}
 }
 
 func (m *GaugeMetric) WithParent(pm *PrometheusMetrics) SingleMetric {
@@ -45,24 +79,5 @@ func (m *GaugeMetric) Set(c *model.Container, s *model.Stats) {
 }
 
 func (m *GaugeMetric) extractLabels(c *model.Container) map[string]string {
-	values := map[string]string{
-		"container_name":  c.Name,
-		"container_image": c.Image,
-	}
-
-	if m.Parent != nil && m.Parent.EngineStats != nil {
-		values["engine_host"] = m.Parent.EngineStats.Host
-	}
-
-	for name, key := range m.Parent.Labels {
-		_, exists := values[key]
-		if exists {
-			continue
-		}
-
-		label, _ := c.Labels[name]
-		values[key] = label
-	}
-
-	return values
+	return m.Parent.extractLabels(c)
 }
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// HealthMetric exposes the health check status of the containers that have one
+type HealthMetric struct {
+	Metric *prometheus.GaugeVec
+
+	Parent *PrometheusMetrics
+}
+
+func newHealthGauge(name, help string, baseLabels []string) *HealthMetric {
+	return &HealthMetric{
+		Metric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName(name),
+			Help: help,
+		}, baseLabels),
+	}
+}
+
+func (m *HealthMetric) Describe(ch chan<- *prometheus.Desc) {
+	m.Metric.Describe(ch)
+}
+
+func (m *HealthMetric) Collect(ch chan<- prometheus.Metric) {
+	m.Metric.Collect(ch)
+}
+
+func (m *HealthMetric) WithParent(pm *PrometheusMetrics) SingleMetric {
+	m.Parent = pm
+	return m
+}
+
+func (m *HealthMetric) Set(c *model.Container, s *model.Stats) {
+	if c.Health == "" {
+		return
+	}
+
+	value := 0.0
+	if c.Health == model.Healthy {
+		value = 1.0
+	}
+
+	m.Metric.With(m.Parent.extractLabels(c)).Set(value)
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// statsHistory is a fixed-size ring buffer of the recent stats of a container
+type statsHistory struct {
+	samples []*model.Stats
+	next    int
+	full    bool
+}
+
+func newStatsHistory(size int) *statsHistory {
+	return &statsHistory{samples: make([]*model.Stats, size)}
+}
+
+func (h *statsHistory) add(s *model.Stats) {
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the samples read after the given time, from the oldest to the latest
+func (h *statsHistory) list(since time.Time) []*model.Stats {
+	var samples []*model.Stats
+	if !h.full {
+		samples = h.samples[:h.next]
+	} else {
+		samples = append(append([]*model.Stats{}, h.samples[h.next:]...), h.samples[:h.next]...)
+	}
+
+	for idx, s := range samples {
+		if !s.Read.Before(since) {
+			return append([]*model.Stats{}, samples[idx:]...)
+		}
+	}
+
+	return []*model.Stats{}
+}
+
+var (
+	histories   = map[string]*statsHistory{}
+	historySize = 0
+	historyAge  time.Duration
+	historyLock sync.Mutex
+)
+
+// SetHistory sets how long and at most how many samples to keep for each container
+func SetHistory(age time.Duration, size int) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	historyAge = age
+	historySize = size
+	histories = map[string]*statsHistory{}
+}
+
+func addHistory(id string, s *model.Stats) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	if historySize <= 0 {
+		return
+	}
+
+	h, ok := histories[id]
+	if !ok {
+		h = newStatsHistory(historySize)
+		histories[id] = h
+	}
+
+	h.add(s)
+}
+
+func getHistory(id string) []*model.Stats {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	if h, ok := histories[id]; ok {
+		return h.list(time.Now().Add(-historyAge))
+	}
+
+	return []*model.Stats{}
+}
+
+// pruneHistory drops the history of the containers that are gone
+func pruneHistory(containers []model.Container) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	current := map[string]bool{}
+	for _, c := range containers {
+		current[c.Id] = true
+	}
+
+	for id := range histories {
+		if !current[id] {
+			delete(histories, id)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestHistoryIsBounded(t *testing.T) {
+	SetHistory(time.Minute, 3)
+	defer SetHistory(0, 0)
+
+	for idx := uint64(1); idx <= 5; idx++ {
+		addHistory("abcd", &model.Stats{Read: time.Now(), CpuStats: model.CpuStats{Total: idx}})
+	}
+
+	history := getHistory("abcd")
+	if len(history) != 3 {
+		t.Fatal("Unexpected history size:", len(history))
+	}
+
+	for idx, s := range history {
+		if s.CpuStats.Total != uint64(idx+3) {
+			t.Error("Unexpected sample at", idx, ":", s.CpuStats.Total)
+		}
+	}
+
+	pruneHistory([]model.Container{{Id: "other"}})
+
+	if history := getHistory("abcd"); len(history) != 0 {
+		t.Error("Expected the history to be pruned, got", len(history))
+	}
+}
+
+func TestHistoryDropsOldSamples(t *testing.T) {
+	SetHistory(time.Minute, 10)
+	defer SetHistory(0, 0)
+
+	now := time.Now()
+	for idx := 3; idx >= 0; idx-- {
+		addHistory("abcd", &model.Stats{Read: now.Add(-time.Duration(idx) * 30 * time.Second)})
+	}
+
+	history := getHistory("abcd")
+	if len(history) != 2 {
+		t.Fatal("Unexpected history size:", len(history))
+	}
+
+	if !history[1].Read.Equal(now) {
+		t.Error("Unexpected latest sample:", history[1].Read)
+	}
+}
+
+func TestRecordCalculatesRates(t *testing.T) {
+	SetHistory(time.Minute, 10)
+	defer SetHistory(0, 0)
+
+	container := model.Container{Id: "rates", Name: "rates"}
+	setCurrent(NewMetrics([]model.Container{container}))
+
+	start := time.Now()
+	samples := []*model.Stats{
+		{Read: start, NetworkStats: model.NetworkStats{RxBytes: 1000}, CpuStats: model.CpuStats{Total: uint64(time.Second)}},
+		{Read: start.Add(2 * time.Second), NetworkStats: model.NetworkStats{RxBytes: 5000}, CpuStats: model.CpuStats{Total: uint64(2 * time.Second)}},
+	}
+
+	for _, sample := range samples {
+		s := sample
+		record(&container, func(*model.Container) (*model.Stats, error) { return s, nil })
+	}
+
+	history := getHistory("rates")
+	if len(history) != 2 {
+		t.Fatal("Unexpected history size:", len(history))
+	}
+
+	rates := history[1].Rates
+	if rates == nil || rates.RxBytes != 2000 || rates.CpuCores != 0.5 {
+		t.Errorf("Unexpected rates: %+v", rates)
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+var (
+	infoLabels     = false
+	infoLabelsLock sync.Mutex
+)
+
+// SetInfoLabels sets whether to put the container labels only on the info metric,
+// and only the container ID, name and engine host on the other metrics
+func SetInfoLabels(enabled bool) {
+	infoLabelsLock.Lock()
+	defer infoLabelsLock.Unlock()
+
+	infoLabels = enabled
+}
+
+func infoLabelsEnabled() bool {
+	infoLabelsLock.Lock()
+	defer infoLabelsLock.Unlock()
+
+	return infoLabels
+}
+
+// ContainerInfoMetric exposes the metadata of the containers as labels on a constant 1 value,
+// so that the image details don't have to be on every other metric
+type ContainerInfoMetric struct {
+	Name string
+	Help string
+	Desc *prometheus.Desc
+
+	// the container labels with their label names on the info metric
+	containerLabels [][2]string
+
+	Parent *PrometheusMetrics
+}
+
+func newContainerInfo(name, help string) *ContainerInfoMetric {
+	return &ContainerInfoMetric{Name: metricName(name), Help: help}
+}
+
+func (m *ContainerInfoMetric) labelNames() []string {
+	names := []string{"", "", "", "", "image_repository", "image_tag", "image_digest", "image_id"}
+
+	for idx, name := range []string{"container.name", "engine.host", "container.image", "container.id"} {
+		names[idx] = m.Parent.Labels[name]
+	}
+
+	if names[2] == "" {
+		names[2] = "container_image"
+	}
+	if names[3] == "" {
+		names[3] = "container_id"
+	}
+
+	used := map[string]bool{}
+	for _, name := range names {
+		used[name] = true
+	}
+
+	m.containerLabels = nil
+
+	for _, label := range sortedLabels(m.Parent.InfoLabels) {
+		// the metadata of the container takes precedence
+		if key := m.Parent.InfoLabels[label]; !used[key] {
+			m.containerLabels = append(m.containerLabels, [2]string{label, key})
+			names = append(names, key)
+			used[key] = true
+		}
+	}
+
+	return names
+}
+
+func sortedLabels(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (m *ContainerInfoMetric) labelValues(c *model.Container) []string {
+	host := ""
+	if engineStats := m.Parent.getEngineStats(); engineStats != nil {
+		host = engineStats.Host
+	}
+
+	values := []string{c.Name, host, c.Image, m.Parent.IDPrefix + c.Id, c.ImageRepository, c.ImageTag, c.ImageDigest, c.ImageID}
+
+	for _, label := range m.containerLabels {
+		values = append(values, m.Parent.limits.limitLabelValue(c.Labels[label[0]]))
+	}
+
+	return values
+}
+
+func (m *ContainerInfoMetric) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.Desc
+}
+
+func (m *ContainerInfoMetric) Collect(ch chan<- prometheus.Metric) {
+	for _, item := range m.Parent.Containers {
+		c := item
+
+		if m.Parent.hidden[c.Id] {
+			continue
+		}
+
+		if metric, err := prometheus.NewConstMetric(m.Desc, prometheus.GaugeValue, 1, m.labelValues(&c)...); err == nil {
+			ch <- metric
+		}
+	}
+}
+
+func (m *ContainerInfoMetric) WithParent(pm *PrometheusMetrics) SingleMetric {
+	m.Parent = pm
+	m.Desc = prometheus.NewDesc(m.Name, m.Help, m.labelNames(), nil)
+	return m
+}
+
+// Set does nothing, the samples are built from the containers when collected
+func (m *ContainerInfoMetric) Set(c *model.Container, s *model.Stats) {}
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// Limits bounds the number of series exposed, for hosts with many containers or labels
+type Limits struct {
+	// MaxLabelValueLength is the maximum length of the label values, or 0 for no limit
+	MaxLabelValueLength int
+	// HashLabelValues replaces the end of the long values with a hash, instead of truncating them
+	HashLabelValues bool
+	// MaxLabelKeys is the maximum number of distinct container labels, or 0 for no limit
+	MaxLabelKeys int
+	// MaxSeries is the maximum number of series for the containers, or 0 for no limit
+	MaxSeries int
+}
+
+var (
+	limits     Limits
+	limitHits  = map[string]uint64{"label_value_length": 0, "label_keys": 0, "series": 0}
+	limitsLock sync.Mutex
+
+	// the label keys, containers or label values each limit applied to the last time
+	limited = map[string]map[string]bool{}
+)
+
+// SetLimits changes the limits for the metrics created after it
+func SetLimits(l Limits) {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	limits = l
+}
+
+func getLimits() Limits {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	return limits
+}
+
+// newLimitHits returns the items the limit did not apply to the last time,
+// so that the same items are not counted again every time the metrics are prepared
+func newLimitHits(limit string, items []string) []string {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	previous := limited[limit]
+	current := make(map[string]bool, len(items))
+
+	var added []string
+	for _, item := range items {
+		current[item] = true
+
+		if !previous[item] {
+			added = append(added, item)
+		}
+	}
+
+	limited[limit] = current
+
+	return added
+}
+
+func countLimitHits(limit string, count int) {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	limitHits[limit] += uint64(count)
+}
+
+// collectLimitHits exposes the number of times the limits were hit
+func collectLimitHits(ch chan<- prometheus.Metric) {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	desc := prometheus.NewDesc(metricName("limit_hits_total"),
+		"Number of label values, label keys or series dropped or changed by the limits", []string{"limit"}, nil)
+
+	for _, limit := range sortedKeys(limitHits) {
+		if metric, err := prometheus.NewConstMetric(desc, prometheus.CounterValue, float64(limitHits[limit]), limit); err == nil {
+			ch <- metric
+		}
+	}
+}
+
+func sortedKeys(values map[string]uint64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// limitLabelKeys returns the sorted names of the container labels up to the limit
+func limitLabelKeys(containers []model.Container, l Limits) []string {
+	distinct := map[string]bool{}
+	for _, c := range containers {
+		for name := range c.Labels {
+			distinct[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(distinct))
+	for name := range distinct {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if l.MaxLabelKeys > 0 && len(names) > l.MaxLabelKeys {
+		dropped := newLimitHits("label_keys", names[l.MaxLabelKeys:])
+
+		if len(dropped) > 0 {
+			log.Println("Label key limit reached, dropping", len(dropped), "labels:", strings.Join(dropped, ", "))
+			countLimitHits("label_keys", len(dropped))
+		}
+
+		names = names[:l.MaxLabelKeys]
+	} else {
+		newLimitHits("label_keys", nil)
+	}
+
+	return names
+}
+
+// limitSeries hides the metrics of the containers, in the order of their names,
+// that would take the number of series over the limit
+func limitSeries(pm *PrometheusMetrics, l Limits) {
+	if l.MaxSeries <= 0 || len(pm.Metrics) == 0 {
+		newLimitHits("series", nil)
+		return
+	}
+
+	maxContainers := l.MaxSeries / len(pm.Metrics)
+	if len(pm.Containers) <= maxContainers {
+		newLimitHits("series", nil)
+		return
+	}
+
+	names := make([]string, len(pm.Containers))
+	ids := make(map[string]string, len(pm.Containers))
+
+	for idx, c := range pm.Containers {
+		names[idx] = c.Name
+		ids[c.Name] = c.Id
+	}
+
+	sort.Strings(names)
+
+	pm.hidden = map[string]bool{}
+	hidden := make([]string, 0, len(names)-maxContainers)
+
+	for _, name := range names[maxContainers:] {
+		pm.hidden[ids[name]] = true
+		hidden = append(hidden, ids[name])
+	}
+
+	if added := newLimitHits("series", hidden); len(added) > 0 {
+		log.Println("Series limit reached, not exposing the metrics of", len(added), "more containers")
+		countLimitHits("series", len(added)*len(pm.Metrics))
+	}
+}
+
+// countLongValues logs and counts the new container label values over the length limit
+func countLongValues(pm *PrometheusMetrics, l Limits) {
+	if l.MaxLabelValueLength <= 0 {
+		newLimitHits("label_value_length", nil)
+		return
+	}
+
+	var long []string
+
+	for _, c := range pm.Containers {
+		if pm.hidden[c.Id] {
+			continue
+		}
+
+		for name, value := range c.Labels {
+			_, exposed := pm.Labels[name]
+			_, exposedOnInfo := pm.InfoLabels[name]
+
+			if (exposed || exposedOnInfo) && len(value) > l.MaxLabelValueLength {
+				long = append(long, c.Id+"/"+name)
+			}
+		}
+	}
+
+	if added := newLimitHits("label_value_length", long); len(added) > 0 {
+		log.Println("Label value length limit reached for", len(added), "values")
+		countLimitHits("label_value_length", len(added))
+	}
+}
+
+// limitLabelValue truncates the value to the maximum length, or replaces
+// its end with a hash of the whole value, so that different values stay different
+func (l Limits) limitLabelValue(value string) string {
+	if l.MaxLabelValueLength <= 0 || len(value) <= l.MaxLabelValueLength {
+		return value
+	}
+
+	if !l.HashLabelValues {
+		return truncateUTF8(value, l.MaxLabelValueLength)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+
+	if l.MaxLabelValueLength <= len(hash)+1 {
+		return hash[:l.MaxLabelValueLength]
+	}
+
+	return truncateUTF8(value, l.MaxLabelValueLength-len(hash)-1) + "_" + hash
+}
+
+// truncateUTF8 cuts the value to at most the given number of bytes, on a character boundary
+func truncateUTF8(value string, length int) string {
+	for length > 0 && !utf8.RuneStart(value[length]) {
+		length--
+	}
+
+	return value[:length]
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestLimitLabelValue(t *testing.T) {
+	truncate := Limits{MaxLabelValueLength: 10}
+	hash := Limits{MaxLabelValueLength: 20, HashLabelValues: true}
+
+	if value := truncate.limitLabelValue("short"); value != "short" {
+		t.Errorf("Unexpected value: %s", value)
+	}
+	if value := truncate.limitLabelValue("0123456789abcdef"); value != "0123456789" {
+		t.Errorf("Unexpected truncated value: %s", value)
+	}
+	if value := truncate.limitLabelValue("012345678é"); value != "012345678" {
+		t.Errorf("Unexpected truncated value: %q", value)
+	}
+
+	first := hash.limitLabelValue("build-0123456789abcdef-1")
+	second := hash.limitLabelValue("build-0123456789abcdef-2")
+
+	if len(first) != 20 || !strings.HasPrefix(first, "build-01234_") || first == second {
+		t.Errorf("Unexpected hashed values: %s %s", first, second)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	perContainer := len(NewMetrics(nil).Metrics)
+
+	SetLimits(Limits{MaxLabelValueLength: 8, MaxLabelKeys: 1, MaxSeries: 2*perContainer + 1})
+	defer SetLimits(Limits{})
+
+	containers := []model.Container{
+		{Id: "c1", Name: "web", Image: "nginx", Labels: map[string]string{"a": "1", "b": "2"}},
+		{Id: "c2", Name: "db", Image: "postgres", Labels: map[string]string{"a": "a-very-long-value"}},
+		{Id: "c3", Name: "cache", Image: "redis"},
+	}
+
+	pm := NewMetrics(containers)
+
+	if _, exists := pm.Labels["b"]; exists {
+		t.Error("Expected the label key limit to drop the second label")
+	}
+
+	// only 2 containers fit in the series limit
+	if len(pm.hidden) != 1 || !pm.hidden["c1"] {
+		t.Errorf("Unexpected hidden containers: %v", pm.hidden)
+	}
+
+	if labels := pm.extractLabels(&containers[1]); labels["a"] != "a-very-l" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+}
+
+func TestLimitHitsCountedOnce(t *testing.T) {
+	perContainer := len(NewMetrics(nil).Metrics)
+
+	SetLimits(Limits{MaxLabelValueLength: 8, MaxLabelKeys: 1, MaxSeries: perContainer + 1})
+	defer SetLimits(Limits{})
+
+	containers := []model.Container{
+		{Id: "c1", Name: "web", Image: "nginx", Labels: map[string]string{"a": "1", "b": "2"}},
+		{Id: "c2", Name: "db", Image: "postgres", Labels: map[string]string{"a": "a-very-long-value"}},
+	}
+
+	NewMetrics(containers)
+	first := gatherLimitHits()
+
+	// a container event prepares the metrics again for the same containers
+	NewMetrics(containers)
+	second := gatherLimitHits()
+
+	for limit, count := range first {
+		if count == 0 {
+			t.Errorf("Expected the %s limit to be hit", limit)
+		}
+		if second[limit] != count {
+			t.Errorf("Unexpected %s limit hits: %v, then %v", limit, count, second[limit])
+		}
+	}
+}
+
+func gatherLimitHits() map[string]float64 {
+	ch := make(chan prometheus.Metric, 3)
+	collectLimitHits(ch)
+	close(ch)
+
+	hits := map[string]float64{}
+	for metric := range ch {
+		var m dto.Metric
+		metric.Write(&m)
+
+		hits[m.Label[0].GetValue()] = m.Counter.GetValue()
+	}
+
+	return hits
+}
+
+func TestLabelValueLimitKeepsContainersApart(t *testing.T) {
+	SetLimits(Limits{MaxLabelValueLength: 10})
+	defer SetLimits(Limits{})
+
+	containers := []model.Container{
+		{Id: "c1", Name: "myproject_worker_1", Image: "myproject/worker", Labels: map[string]string{"com.example.tier": "background-jobs"}},
+		{Id: "c2", Name: "myproject_worker_2", Image: "myproject/worker", Labels: map[string]string{"com.example.tier": "background-jobs"}},
+	}
+
+	pm := NewMetrics(containers)
+	registry := prometheus.NewPedanticRegistry()
+
+	for _, metric := range pm.Metrics {
+		for idx := range containers {
+			metric.Set(&containers[idx], &model.Stats{})
+		}
+
+		registry.MustRegister(metric)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather the metrics:", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != metricName("memory_usage_bytes") {
+			continue
+		}
+
+		if len(family.Metric) != 2 {
+			t.Fatalf("Unexpected number of series: %d", len(family.Metric))
+		}
+
+		for _, metric := range family.Metric {
+			for _, pair := range metric.Label {
+				if pair.GetName() == "com_example_tier" && pair.GetValue() != "background" {
+					t.Errorf("Unexpected container label value: %s", pair.GetValue())
+				}
+				if pair.GetName() == "container_name" && len(pair.GetValue()) != 18 {
+					t.Errorf("Unexpected container name: %s", pair.GetValue())
+				}
+			}
+		}
+
+		return
+	}
+
+	t.Error("Expected the memory usage metric")
+}
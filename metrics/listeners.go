@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+// Listener is notified about every new stats sample collected
+type Listener interface {
+	OnStats(*model.Container, *model.Stats)
+	OnEngineStats(*model.EngineStats)
+}
+
+var (
+	listeners     []Listener
+	listenersLock sync.Mutex
+)
+
+func AddListener(l Listener) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	listeners = append(listeners, l)
+}
+
+func RemoveListener(l Listener) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	remaining := make([]Listener, 0, len(listeners))
+	for _, existing := range listeners {
+		if existing != l {
+			remaining = append(remaining, existing)
+		}
+	}
+
+	listeners = remaining
+}
+
+func getListeners() []Listener {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	return listeners
+}
+
+func notifyStats(c *model.Container, s *model.Stats) {
+	for _, l := range getListeners() {
+		l.OnStats(c, s)
+	}
+}
+
+func notifyEngineStats(s *model.EngineStats) {
+	for _, l := range getListeners() {
+		l.OnEngineStats(s)
+	}
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/rycus86/container-metrics/model"
@@ -9,10 +11,32 @@ import (
 type PrometheusMetrics struct {
 	Containers []model.Container
 	Labels     map[string]string // {container.label} -> {prometheus_label}
+	InfoLabels map[string]string // the container labels only on the info metric
+	IDPrefix   string
 	Metrics    []SingleMetric
 
-	EngineStats   *model.EngineStats
 	EngineMetrics []EngineMetric
+
+	engineStats *model.EngineStats
+	engineLock  sync.Mutex
+
+	limits Limits
+	hidden map[string]bool // the containers over the series limit
+}
+
+// getEngineStats returns the latest engine stats, recorded concurrently with the collections
+func (pm *PrometheusMetrics) getEngineStats() *model.EngineStats {
+	pm.engineLock.Lock()
+	defer pm.engineLock.Unlock()
+
+	return pm.engineStats
+}
+
+func (pm *PrometheusMetrics) setEngineStats(stats *model.EngineStats) {
+	pm.engineLock.Lock()
+	defer pm.engineLock.Unlock()
+
+	pm.engineStats = stats
 }
 
 type SingleMetric interface {
@@ -47,3 +71,45 @@ func (pm *PrometheusMetrics) GetLabelNames() []string {
 
 	return labelNames
 }
+
+func (pm *PrometheusMetrics) extractLabels(c *model.Container) map[string]string {
+	values := map[string]string{}
+
+	for name, key := range pm.Labels {
+		switch name {
+		case "container.name":
+			values[key] = c.Name
+		case "container.image":
+			values[key] = c.Image
+		case "container.id":
+			values[key] = pm.IDPrefix + c.Id
+		case "engine.host":
+			if engineStats := pm.getEngineStats(); engineStats != nil {
+				values[key] = engineStats.Host
+			}
+		}
+	}
+
+	for name, key := range pm.Labels {
+		_, exists := values[key]
+		if exists {
+			continue
+		}
+
+		// only the container labels are limited, the others identify the container
+		values[key] = pm.limits.limitLabelValue(c.Labels[name])
+	}
+
+	return values
+}
+
+func (pm *PrometheusMetrics) labelValues(c *model.Container, labelNames []string) []string {
+	values := pm.extractLabels(c)
+	ordered := make([]string, len(labelNames), len(labelNames))
+
+	for idx, name := range labelNames {
+		ordered[idx] = values[name]
+	}
+
+	return ordered
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// NamingDefault names the metrics with the namespace, like cntm_cpu_usage_percent
+	NamingDefault = "default"
+	// NamingCAdvisor names the metrics and their labels the same way as cAdvisor,
+	// like container_cpu_usage_seconds_total{name="...",image="...",id="..."}
+	NamingCAdvisor = "cadvisor"
+)
+
+// cadvisorNames maps the metrics to the names cAdvisor exposes them with,
+// the others keep their names with the namespace
+var cadvisorNames = map[string]string{
+	"cpu_usage_total_seconds":  "container_cpu_usage_seconds_total",
+	"cpu_usage_system_seconds": "container_cpu_system_seconds_total",
+	"cpu_usage_user_seconds":   "container_cpu_user_seconds_total",
+
+	"memory_total_bytes": "container_spec_memory_limit_bytes",
+	"memory_usage_bytes": "container_memory_working_set_bytes",
+
+	"io_read_bytes":  "container_fs_reads_bytes_total",
+	"io_write_bytes": "container_fs_writes_bytes_total",
+
+	"net_rx_bytes":   "container_network_receive_bytes_total",
+	"net_rx_packets": "container_network_receive_packets_total",
+	"net_rx_dropped": "container_network_receive_packets_dropped_total",
+	"net_rx_errors":  "container_network_receive_errors_total",
+	"net_tx_bytes":   "container_network_transmit_bytes_total",
+	"net_tx_packets": "container_network_transmit_packets_total",
+	"net_tx_dropped": "container_network_transmit_packets_dropped_total",
+	"net_tx_errors":  "container_network_transmit_errors_total",
+}
+
+var (
+	namespace     = "cntm"
+	namingProfile = NamingDefault
+	metricNames   = map[string]string{}
+	namingLock    sync.Mutex
+)
+
+// SetNaming changes the namespace and the naming profile of the metrics created after it
+func SetNaming(ns, profile string) {
+	namingLock.Lock()
+	defer namingLock.Unlock()
+
+	namespace = ns
+	namingProfile = profile
+}
+
+// SetMetricNames changes the names of the metrics created after it, by their names
+// without the namespace, like cpu_usage_percent, taking precedence over the naming profile
+func SetMetricNames(names map[string]string) {
+	namingLock.Lock()
+	defer namingLock.Unlock()
+
+	metricNames = map[string]string{}
+	for name, override := range names {
+		metricNames[name] = override
+	}
+}
+
+func getMetricName(name string) (string, bool) {
+	namingLock.Lock()
+	defer namingLock.Unlock()
+
+	override, exists := metricNames[name]
+	return override, exists
+}
+
+func getNaming() (string, string) {
+	namingLock.Lock()
+	defer namingLock.Unlock()
+
+	return namespace, namingProfile
+}
+
+// metricName returns the full name of the metric for the current naming profile,
+// or the name it was overridden with
+func metricName(name string) string {
+	if override, exists := getMetricName(name); exists {
+		return override
+	}
+
+	ns, profile := getNaming()
+
+	if profile == NamingCAdvisor {
+		if mapped, exists := cadvisorNames[name]; exists {
+			return mapped
+		}
+	}
+
+	return prometheus.BuildFQName(ns, "", name)
+}
+
+// labelNaming holds the names of the labels for the container metadata,
+// the prefix for the names of the container labels, and for the container IDs
+type labelNaming struct {
+	base        map[string]string
+	labelPrefix string
+	idPrefix    string
+}
+
+func baseLabelNames() labelNaming {
+	_, profile := getNaming()
+	return baseLabelNamesFor(profile)
+}
+
+func baseLabelNamesFor(profile string) labelNaming {
+	if profile == NamingCAdvisor {
+		return labelNaming{
+			base: map[string]string{
+				"container.name":  "name",
+				"container.image": "image",
+				"container.id":    "id",
+				"engine.host":     "engine_host",
+			},
+			labelPrefix: "container_label_",
+			// the same format cAdvisor uses for the Docker containers
+			idPrefix: "/docker/",
+		}
+	}
+
+	return labelNaming{
+		base: map[string]string{
+			"container.name":  "container_name",
+			"container.image": "container_image",
+			"engine.host":     "engine_host",
+		},
+	}
+}
+
+// ReservedLabelName returns true if the container label would be exposed with the same name
+// as a label of the container metadata with the naming profile, like container_name,
+// including the container_id label of the info labels
+func ReservedLabelName(profile, label string) bool {
+	naming := baseLabelNamesFor(profile)
+	name := naming.labelPrefix + SanitizeName(label)
+
+	if name == "container_id" {
+		return true
+	}
+
+	for _, base := range naming.base {
+		if name == base {
+			return true
+		}
+	}
+
+	return false
+}
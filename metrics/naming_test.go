@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestCAdvisorNaming(t *testing.T) {
+	SetNaming("docker", NamingCAdvisor)
+	defer SetNaming("cntm", NamingDefault)
+
+	c := model.Container{Id: "abcdef", Name: "web", Image: "nginx", Labels: map[string]string{"com.example.team": "ops"}}
+
+	pm := NewMetrics([]model.Container{c})
+	pm.setEngineStats(&model.EngineStats{Host: "pi-01"})
+
+	expected := map[string]string{
+		"name":                             "web",
+		"image":                            "nginx",
+		"id":                               "/docker/abcdef",
+		"engine_host":                      "pi-01",
+		"container_label_com_example_team": "ops",
+	}
+
+	if labels := pm.extractLabels(&c); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+
+	for name, expected := range map[string]string{
+		"cpu_usage_total_seconds": "container_cpu_usage_seconds_total",
+		"net_rx_bytes":            "container_network_receive_bytes_total",
+		"cpu_usage_percent":       "docker_cpu_usage_percent",
+	} {
+		if actual := metricName(name); actual != expected {
+			t.Errorf("Unexpected name for %s: %s", name, actual)
+		}
+	}
+
+	found := false
+	for _, metric := range pm.Metrics {
+		if gauge, ok := metric.(*GaugeMetric); ok && strings.Contains(gauge.Desc.String(), `"container_memory_working_set_bytes"`) {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("Expected the working set metric of cAdvisor")
+	}
+}
+
+func TestDefaultNaming(t *testing.T) {
+	SetNaming("", NamingDefault)
+	defer SetNaming("cntm", NamingDefault)
+
+	if name := metricName("cpu_usage_total_seconds"); name != "cpu_usage_total_seconds" {
+		t.Errorf("Unexpected name without a namespace: %s", name)
+	}
+
+	c := model.Container{Id: "abcdef", Name: "web", Image: "nginx", Labels: map[string]string{"com.example.team": "ops"}}
+	pm := NewMetrics([]model.Container{c})
+	pm.setEngineStats(&model.EngineStats{Host: "pi-01"})
+
+	expected := map[string]string{
+		"container_name":   "web",
+		"container_image":  "nginx",
+		"engine_host":      "pi-01",
+		"com_example_team": "ops",
+	}
+
+	if labels := pm.extractLabels(&c); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+}
+
+func TestMetricNameOverrides(t *testing.T) {
+	SetNaming("docker", NamingCAdvisor)
+	SetMetricNames(map[string]string{"cpu_usage_percent": "container_cpu_percent", "net_rx_bytes": "rx_bytes_total"})
+	defer SetNaming("cntm", NamingDefault)
+	defer SetMetricNames(nil)
+
+	for name, expected := range map[string]string{
+		"cpu_usage_percent":       "container_cpu_percent",
+		"net_rx_bytes":            "rx_bytes_total",
+		"cpu_usage_total_seconds": "container_cpu_usage_seconds_total",
+		"memory_usage_percent":    "docker_memory_usage_percent",
+	} {
+		if actual := metricName(name); actual != expected {
+			t.Errorf("Unexpected name for %s: %s", name, actual)
+		}
+	}
+}
+
+func TestReservedLabelName(t *testing.T) {
+	for _, item := range []struct {
+		profile  string
+		label    string
+		expected bool
+	}{
+		{NamingDefault, "container_name", true},
+		{NamingDefault, "container.image", true},
+		{NamingDefault, "engine-host", true},
+		{NamingDefault, "container_id", true},
+		{NamingDefault, "name", false},
+		{NamingDefault, "service", false},
+		{NamingCAdvisor, "name", false},
+		{NamingCAdvisor, "container_name", false},
+	} {
+		if ReservedLabelName(item.profile, item.label) != item.expected {
+			t.Errorf("Unexpected result for %s with the %s naming", item.label, item.profile)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	openMetricsType        = "application/openmetrics-text"
+	openMetricsContentType = openMetricsType + "; version=1.0.0; charset=utf-8"
+)
+
+var metricUnits = []string{"seconds", "bytes", "percent"}
+
+// metricsHandler serves the OpenMetrics format when the client prefers it,
+// and falls back to the classic formats supported by promhttp otherwise
+func metricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	classic := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsOpenMetrics(r.Header.Get("Accept")) {
+			classic.ServeHTTP(w, r)
+			return
+		}
+
+		mfs, err := gatherer.Gather()
+		if err != nil && len(mfs) == 0 {
+			http.Error(w, "An error has occurred during metrics gathering:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		var out io.Writer = buf
+		var gz *gzip.Writer
+
+		if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			gz = gzip.NewWriter(buf)
+			out = gz
+		}
+
+		if err := writeOpenMetrics(out, mfs); err != nil {
+			http.Error(w, "An error has occurred during metrics encoding:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Content-Type", openMetricsContentType)
+
+		if gz != nil {
+			gz.Close()
+			header.Set("Content-Encoding", "gzip")
+		}
+
+		header.Set("Content-Length", strconv.Itoa(buf.Len()))
+
+		w.Write(buf.Bytes())
+	})
+}
+
+// acceptsOpenMetrics checks if the OpenMetrics format has the highest
+// quality factor among the formats accepted by the client
+func acceptsOpenMetrics(accept string) bool {
+	openMetricsQuality, otherQuality := -1.0, -1.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+
+		if mediaType == openMetricsType {
+			if quality > openMetricsQuality {
+				openMetricsQuality = quality
+			}
+		} else if mediaType == "text/plain" || mediaType == "application/vnd.google.protobuf" {
+			if quality > otherQuality {
+				otherQuality = quality
+			}
+		}
+	}
+
+	return openMetricsQuality > 0 && openMetricsQuality >= otherQuality
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		part = strings.TrimSpace(part)
+		if part == "gzip" || strings.HasPrefix(part, "gzip;") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeOpenMetrics(w io.Writer, mfs []*dto.MetricFamily) error {
+	out := bufio.NewWriter(w)
+
+	for _, mf := range mfs {
+		writeOpenMetricsFamily(out, mf)
+	}
+
+	out.WriteString("# EOF\n")
+	return out.Flush()
+}
+
+func writeOpenMetricsFamily(out *bufio.Writer, mf *dto.MetricFamily) {
+	name := mf.GetName()
+	metricType := "unknown"
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		metricType = "counter"
+		name = strings.TrimSuffix(name, "_total")
+	case dto.MetricType_GAUGE:
+		metricType = "gauge"
+	case dto.MetricType_SUMMARY:
+		metricType = "summary"
+	case dto.MetricType_HISTOGRAM:
+		metricType = "histogram"
+	}
+
+	fmt.Fprintf(out, "# TYPE %s %s\n", name, metricType)
+	if unit := unitOf(name); unit != "" {
+		fmt.Fprintf(out, "# UNIT %s %s\n", name, unit)
+	}
+	if mf.GetHelp() != "" {
+		fmt.Fprintf(out, "# HELP %s %s\n", name, escapeOpenMetrics(mf.GetHelp()))
+	}
+
+	for _, m := range mf.GetMetric() {
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			writeOpenMetricsSample(out, name+"_total", m, "", "", m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			writeOpenMetricsSample(out, name, m, "", "", m.GetGauge().GetValue())
+		case dto.MetricType_SUMMARY:
+			for _, q := range m.GetSummary().GetQuantile() {
+				writeOpenMetricsSample(out, name, m, "quantile", formatFloat(q.GetQuantile()), q.GetValue())
+			}
+			writeOpenMetricsSample(out, name+"_sum", m, "", "", m.GetSummary().GetSampleSum())
+			writeOpenMetricsSample(out, name+"_count", m, "", "", float64(m.GetSummary().GetSampleCount()))
+		case dto.MetricType_HISTOGRAM:
+			buckets := m.GetHistogram().GetBucket()
+			sort.Slice(buckets, func(i, j int) bool {
+				return buckets[i].GetUpperBound() < buckets[j].GetUpperBound()
+			})
+
+			hasInf := false
+			for _, b := range buckets {
+				hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+				writeOpenMetricsSample(out, name+"_bucket", m, "le", formatFloat(b.GetUpperBound()), float64(b.GetCumulativeCount()))
+			}
+			if !hasInf {
+				writeOpenMetricsSample(out, name+"_bucket", m, "le", "+Inf", float64(m.GetHistogram().GetSampleCount()))
+			}
+
+			writeOpenMetricsSample(out, name+"_sum", m, "", "", m.GetHistogram().GetSampleSum())
+			writeOpenMetricsSample(out, name+"_count", m, "", "", float64(m.GetHistogram().GetSampleCount()))
+		default:
+			writeOpenMetricsSample(out, name, m, "", "", m.GetUntyped().GetValue())
+		}
+	}
+}
+
+func writeOpenMetricsSample(out *bufio.Writer, name string, m *dto.Metric, extraName, extraValue string, value float64) {
+	out.WriteString(name)
+
+	labels := m.GetLabel()
+	if len(labels) > 0 || extraName != "" {
+		out.WriteByte('{')
+
+		for idx, label := range labels {
+			if idx > 0 {
+				out.WriteByte(',')
+			}
+
+			fmt.Fprintf(out, "%s=\"%s\"", label.GetName(), escapeOpenMetrics(label.GetValue()))
+		}
+
+		if extraName != "" {
+			if len(labels) > 0 {
+				out.WriteByte(',')
+			}
+
+			fmt.Fprintf(out, "%s=\"%s\"", extraName, extraValue)
+		}
+
+		out.WriteByte('}')
+	}
+
+	out.WriteByte(' ')
+	out.WriteString(formatFloat(value))
+
+	if m.TimestampMs != nil {
+		// OpenMetrics timestamps are in seconds
+		out.WriteByte(' ')
+		out.WriteString(strconv.FormatFloat(float64(m.GetTimestampMs())/1000.0, 'f', -1, 64))
+	}
+
+	out.WriteByte('\n')
+}
+
+func unitOf(name string) string {
+	for _, unit := range metricUnits {
+		if strings.HasSuffix(name, "_"+unit) {
+			return unit
+		}
+	}
+
+	return ""
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeOpenMetrics(value string) string {
+	return openMetricsEscaper.Replace(value)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("cntm_memory_usage_bytes"),
+			Help: proto.String("Memory usage"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{
+						{Name: proto.String("container_name"), Value: proto.String("sample \"one\"")},
+					},
+					Gauge:       &dto.Gauge{Value: proto.Float64(1024)},
+					TimestampMs: proto.Int64(1500000000500),
+				},
+			},
+		},
+		{
+			Name: proto.String("process_cpu_seconds_total"),
+			Help: proto.String("Total user and system CPU time spent in seconds."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{Counter: &dto.Counter{Value: proto.Float64(1.5)}},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeOpenMetrics(buf, mfs); err != nil {
+		t.Fatal("Failed to write metrics:", err)
+	}
+
+	expected := `# TYPE cntm_memory_usage_bytes gauge
+# UNIT cntm_memory_usage_bytes bytes
+# HELP cntm_memory_usage_bytes Memory usage
+cntm_memory_usage_bytes{container_name="sample \"one\""} 1024 1500000000.5
+# TYPE process_cpu_seconds counter
+# UNIT process_cpu_seconds seconds
+# HELP process_cpu_seconds Total user and system CPU time spent in seconds.
+process_cpu_seconds_total 1.5
+# EOF
+`
+
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestAcceptsOpenMetrics(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                             false,
+		"text/plain;version=0.0.4":     false,
+		"application/openmetrics-text": true,
+		"application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1": true,
+		"application/openmetrics-text;q=0.2,text/plain;q=0.8":                                 false,
+	} {
+		if acceptsOpenMetrics(accept) != expected {
+			t.Error("Unexpected result for", accept)
+		}
+	}
+}
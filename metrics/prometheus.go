@@ -7,13 +7,10 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/rycus86/container-metrics/model"
 )
 
-const defaultNamespace = "cntm"
-
 var noCachedStats = errors.New("Previous stats not available")
 
 type currentMetricsCollector struct{}
@@ -37,6 +34,8 @@ func (c *currentMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	for _, metric := range current.EngineMetrics {
 		metric.Collect(ch)
 	}
+
+	collectLimitHits(ch)
 }
 
 func init() {
@@ -47,6 +46,11 @@ func RecordEngineStats(stats *model.EngineStats) {
 	if current := getCurrent(); current != nil {
 		recordEngineStatsOn(current, stats)
 	}
+
+	if stats != nil {
+		notifyEngineStats(stats)
+		publishEngineStats(stats)
+	}
 }
 
 func recordEngineStatsOn(pm *PrometheusMetrics, stats *model.EngineStats) {
@@ -54,7 +58,7 @@ func recordEngineStatsOn(pm *PrometheusMetrics, stats *model.EngineStats) {
 		return
 	}
 
-	pm.EngineStats = stats
+	pm.setEngineStats(stats)
 
 	for _, metric := range pm.EngineMetrics {
 		metric.Set(stats)
@@ -62,7 +66,12 @@ func recordEngineStatsOn(pm *PrometheusMetrics, stats *model.EngineStats) {
 }
 
 func PrepareMetrics(containers []model.Container) {
+	if previous := getCurrent(); previous != nil {
+		publishContainerChanges(previous.Containers, containers)
+	}
+
 	setCurrent(NewMetrics(containers))
+	pruneHistory(containers)
 	RecordAll(recordCached)
 }
 
@@ -75,7 +84,7 @@ func recordCached(c *model.Container) (*model.Stats, error) {
 	}
 }
 
-func RecordAll(statsFunc func(*model.Container) (*model.Stats, error)) {
+func RecordAll(statsFunc StatsFunc) {
 	containers := getCurrent().Containers
 
 	for _, item := range containers {
@@ -84,7 +93,7 @@ func RecordAll(statsFunc func(*model.Container) (*model.Stats, error)) {
 	}
 }
 
-func record(c *model.Container, statsFunc func(*model.Container) (*model.Stats, error)) {
+func record(c *model.Container, statsFunc StatsFunc) {
 	s, err := statsFunc(c)
 	if err != nil {
 		if err != noCachedStats {
@@ -94,16 +103,31 @@ func record(c *model.Container, statsFunc func(*model.Container) (*model.Stats,
 		return
 	}
 
-	for _, metric := range getCurrent().Metrics {
-		metric.Set(c, s)
+	if current := getCurrent(); !current.hidden[c.Id] {
+		for _, metric := range current.Metrics {
+			metric.Set(c, s)
+		}
 	}
 
-	cacheStats(c.Id, s)
+	// only notify about new samples, not the ones reloaded from the cache
+	if previous := getCached(c.Id); previous != s {
+		s.Rates = model.CalculateRates(previous, s)
+
+		cacheStats(c.Id, s)
+		addHistory(c.Id, s)
+		notifyStats(c, s)
+		publishStats(c, s)
+	}
 }
 
 func Serve(port int) {
 	log.Println("Serving metrics on port", port)
 
-	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/metrics", scrapeHandler(prometheus.DefaultGatherer))
+	http.HandleFunc(apiPrefix+"containers", serveContainers)
+	http.HandleFunc(apiPrefix+"containers/", serveContainer)
+	http.HandleFunc(apiPrefix+"engine", serveEngine)
+	http.HandleFunc(apiPrefix+"stream", serveStream)
+	http.HandleFunc("/", serveUI)
 	http.ListenAndServe(":"+strconv.Itoa(port), nil)
 }
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+	scrapeTimeoutOffset = 500 * time.Millisecond
+)
+
+type StatsFunc func(*model.Container) (*model.Stats, error)
+
+type EngineStatsFunc func() (*model.EngineStats, error)
+
+type onScrapeCollection struct {
+	statsFunc  StatsFunc
+	engineFunc EngineStatsFunc
+	cacheFor   time.Duration
+	timeout    time.Duration
+
+	lock      sync.Mutex
+	collected time.Time
+	inFlight  chan struct{}
+}
+
+var (
+	onScrape     *onScrapeCollection
+	onScrapeLock sync.Mutex
+)
+
+// CollectOnScrape switches to collecting the stats when the metrics are scraped,
+// reusing the results of the last collection for the cacheFor duration
+func CollectOnScrape(statsFunc StatsFunc, engineFunc EngineStatsFunc, cacheFor, timeout time.Duration) {
+	onScrapeLock.Lock()
+	defer onScrapeLock.Unlock()
+
+	onScrape = &onScrapeCollection{
+		statsFunc:  statsFunc,
+		engineFunc: engineFunc,
+		cacheFor:   cacheFor,
+		timeout:    timeout,
+	}
+}
+
+func getOnScrape() *onScrapeCollection {
+	onScrapeLock.Lock()
+	defer onScrapeLock.Unlock()
+
+	return onScrape
+}
+
+// collect waits for a fresh collection, or one already in progress, to finish
+// and returns early if the cached results are still valid or the timeout expires,
+// which is the default one when not positive
+func (osc *onScrapeCollection) collect(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = osc.timeout
+	}
+
+	osc.lock.Lock()
+
+	if time.Since(osc.collected) < osc.cacheFor {
+		osc.lock.Unlock()
+		return
+	}
+
+	done := osc.inFlight
+	if done == nil {
+		done = make(chan struct{})
+		osc.inFlight = done
+
+		go osc.run(done)
+	}
+
+	osc.lock.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		if logging.IsDebugEnabled() {
+			log.Println("Collection did not finish within", timeout)
+		}
+	}
+}
+
+func (osc *onScrapeCollection) run(done chan struct{}) {
+	if logging.IsVerboseEnabled() {
+		log.Println("Collecting metrics on scrape")
+	}
+
+	if engineStats, err := osc.engineFunc(); err != nil {
+		log.Println("Failed to collect engine stats", err)
+	} else {
+		RecordEngineStats(engineStats)
+	}
+
+	wg := sync.WaitGroup{}
+
+	for _, item := range getCurrent().Containers {
+		current := item
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record(&current, osc.statsFunc)
+		}()
+	}
+
+	wg.Wait()
+
+	osc.lock.Lock()
+	osc.collected = time.Now()
+	osc.inFlight = nil
+	osc.lock.Unlock()
+
+	close(done)
+}
+
+// Gatherer gathers the registered metrics, collecting the stats first in the scrape mode
+var Gatherer prometheus.Gatherer = onScrapeGatherer(prometheus.DefaultGatherer, 0)
+
+// onScrapeGatherer collects the stats before gathering the metrics in the scrape mode,
+// bounded by the timeout, or the default one when not positive
+func onScrapeGatherer(gatherer prometheus.Gatherer, timeout time.Duration) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		if osc := getOnScrape(); osc != nil {
+			osc.collect(timeout)
+		}
+
+		return gatherer.Gather()
+	})
+}
+
+// scrapeHandler bounds the collection triggered by the scrape
+// with the timeout sent by Prometheus
+func scrapeHandler(gatherer prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, _ := parseScrapeTimeout(r.Header.Get(scrapeTimeoutHeader))
+
+		metricsHandler(onScrapeGatherer(gatherer, timeout)).ServeHTTP(w, r)
+	})
+}
+
+func parseScrapeTimeout(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*scrapeTimeoutOffset {
+		// leave some time to encode and send the response
+		timeout -= scrapeTimeoutOffset
+	}
+
+	return timeout, true
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestCollectOnScrape(t *testing.T) {
+	defer func() { onScrape = nil }()
+
+	setCurrent(NewMetrics([]model.Container{
+		{Id: "c1", Name: "scraped"},
+	}))
+
+	var calls int32
+
+	CollectOnScrape(func(c *model.Container) (*model.Stats, error) {
+		atomic.AddInt32(&calls, 1)
+		return &model.Stats{Id: c.Id, Name: c.Name}, nil
+	}, func() (*model.EngineStats, error) {
+		return &model.EngineStats{Host: "test"}, nil
+	}, time.Minute, time.Second)
+
+	getOnScrape().collect(0)
+	getOnScrape().collect(0)
+
+	if calls != 1 {
+		t.Error("Unexpected number of collections:", calls)
+	}
+
+	if cached := getCached("c1"); cached == nil || cached.Name != "scraped" {
+		t.Error("Unexpected cached stats:", cached)
+	}
+}
+
+func TestScrapeTimeoutPerRequest(t *testing.T) {
+	defer func() { onScrape = nil }()
+
+	setCurrent(NewMetrics([]model.Container{
+		{Id: "c1", Name: "slow"},
+	}))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	CollectOnScrape(func(c *model.Container) (*model.Stats, error) {
+		<-release
+		return &model.Stats{Id: c.Id, Name: c.Name}, nil
+	}, func() (*model.EngineStats, error) {
+		return &model.EngineStats{Host: "test"}, nil
+	}, 0, time.Minute)
+
+	handler := scrapeHandler(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, nil
+	}))
+
+	request := httptest.NewRequest("GET", "/metrics", nil)
+	request.Header.Set(scrapeTimeoutHeader, "0.1")
+
+	started := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Error("The scrape timeout was not used:", elapsed)
+	}
+
+	if timeout := getOnScrape().timeout; timeout != time.Minute {
+		t.Error("Unexpected default timeout after the scrape:", timeout)
+	}
+}
+
+func TestParseScrapeTimeout(t *testing.T) {
+	if timeout, ok := parseScrapeTimeout("10"); !ok || timeout != 9500*time.Millisecond {
+		t.Error("Unexpected timeout:", timeout)
+	}
+
+	if timeout, ok := parseScrapeTimeout("0.5"); !ok || timeout != 500*time.Millisecond {
+		t.Error("Unexpected timeout:", timeout)
+	}
+
+	if _, ok := parseScrapeTimeout("invalid"); ok {
+		t.Error("Expected to fail parsing")
+	}
+}
@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	defaultMaxStreamClients = 16
+	streamClientBuffer      = 64
+	streamKeepAlive         = 15 * time.Second
+)
+
+type streamEvent struct {
+	name string
+	data []byte
+}
+
+type streamClient struct {
+	events  chan streamEvent
+	dropped int
+}
+
+var (
+	streamClients    = map[*streamClient]bool{}
+	maxStreamClients = defaultMaxStreamClients
+	streamLock       sync.Mutex
+)
+
+// SetMaxStreamClients limits the number of clients connected to the live stream
+func SetMaxStreamClients(max int) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	maxStreamClients = max
+}
+
+func subscribe() *streamClient {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	if len(streamClients) >= maxStreamClients {
+		return nil
+	}
+
+	client := &streamClient{events: make(chan streamEvent, streamClientBuffer)}
+	streamClients[client] = true
+
+	return client
+}
+
+func unsubscribe(client *streamClient) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	delete(streamClients, client)
+}
+
+func hasStreamClients() bool {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	return len(streamClients) > 0
+}
+
+// publish sends the event to every connected client without blocking,
+// dropping it for the clients that are too slow to keep up
+func publish(name string, value interface{}) {
+	if !hasStreamClients() {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Println("Failed to encode the stream event", err)
+		return
+	}
+
+	event := streamEvent{name: name, data: data}
+
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	for client := range streamClients {
+		select {
+		case client.events <- event:
+		default:
+			client.dropped++
+		}
+	}
+}
+
+func publishStats(c *model.Container, s *model.Stats) {
+	publish("stats", containerResponse{Container: *c, Stats: s})
+}
+
+func publishEngineStats(s *model.EngineStats) {
+	publish("engine", s)
+}
+
+// publishContainerChanges sends start and stop events
+// for the containers that appeared or disappeared
+func publishContainerChanges(previous, current []model.Container) {
+	known := map[string]bool{}
+	for _, c := range previous {
+		known[c.Id] = true
+	}
+
+	running := map[string]bool{}
+	for _, c := range current {
+		running[c.Id] = true
+
+		if !known[c.Id] {
+			publish("start", containerResponse{Container: c})
+		}
+	}
+
+	for _, c := range previous {
+		if !running[c.Id] {
+			publish("stop", containerResponse{Container: c})
+		}
+	}
+}
+
+// serveStream sends the new stats and container events as Server-Sent Events
+func serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"Streaming is not supported"})
+		return
+	}
+
+	client := subscribe()
+	if client == nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{"Too many stream clients"})
+		return
+	}
+	defer unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event := <-client.events:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if dropped := takeDropped(client); dropped > 0 && logging.IsDebugEnabled() {
+				log.Println("Dropped", dropped, "events for a slow stream client")
+			}
+
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func takeDropped(client *streamClient) int {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	dropped := client.dropped
+	client.dropped = 0
+
+	return dropped
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestStreamClientsAreBounded(t *testing.T) {
+	SetMaxStreamClients(1)
+	defer SetMaxStreamClients(defaultMaxStreamClients)
+
+	first := subscribe()
+	if first == nil {
+		t.Fatal("Failed to subscribe")
+	}
+	defer unsubscribe(first)
+
+	if second := subscribe(); second != nil {
+		unsubscribe(second)
+		t.Error("Expected the second subscription to be rejected")
+	}
+}
+
+func TestSlowStreamClientsDoNotBlock(t *testing.T) {
+	client := subscribe()
+	defer unsubscribe(client)
+
+	for idx := 0; idx < streamClientBuffer+10; idx++ {
+		publishEngineStats(&model.EngineStats{Host: "test"})
+	}
+
+	if dropped := takeDropped(client); dropped != 10 {
+		t.Error("Unexpected number of dropped events:", dropped)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveStream))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Error("Unexpected content type:", contentType)
+	}
+
+	for start := time.Now(); !hasStreamClients(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("The client did not subscribe")
+		}
+	}
+
+	publishContainerChanges(
+		[]model.Container{{Id: "a", Name: "old"}},
+		[]model.Container{{Id: "b", Name: "new"}},
+	)
+
+	reader := bufio.NewReader(response.Body)
+
+	var events []string
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("Failed to read the stream:", err)
+		}
+
+		if strings.HasPrefix(line, "event: ") {
+			event := strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+
+			data, _ := reader.ReadString('\n')
+			events = append(events, event+" "+strings.TrimSpace(data))
+		}
+	}
+
+	if !strings.HasPrefix(events[0], "start data: ") || !strings.Contains(events[0], `"name":"new"`) {
+		t.Error("Unexpected start event:", events[0])
+	}
+
+	if !strings.HasPrefix(events[1], "stop data: ") || !strings.Contains(events[1], `"name":"old"`) {
+		t.Error("Unexpected stop event:", events[1])
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+var (
+	timestamps     = false
+	timestampsLock sync.Mutex
+)
+
+// SetTimestamps sets whether to attach the time the Docker daemon
+// has read the container stats at to the exposed samples
+func SetTimestamps(enabled bool) {
+	timestampsLock.Lock()
+	defer timestampsLock.Unlock()
+
+	timestamps = enabled
+}
+
+func timestampsEnabled() bool {
+	timestampsLock.Lock()
+	defer timestampsLock.Unlock()
+
+	return timestamps
+}
+
+type timestampedMetric struct {
+	prometheus.Metric
+
+	timestamp time.Time
+}
+
+func (m *timestampedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+
+	out.TimestampMs = proto.Int64(m.timestamp.UnixNano() / int64(time.Millisecond))
+	return nil
+}
+
+func newTimestampedGauge(desc *prometheus.Desc, value float64, timestamp time.Time, labelValues []string) (prometheus.Metric, error) {
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	if err != nil {
+		return nil, err
+	}
+
+	if timestamp.IsZero() {
+		return metric, nil
+	}
+
+	return &timestampedMetric{Metric: metric, timestamp: timestamp}, nil
+}
+
+type StatsAgeMetric struct {
+	Desc       *prometheus.Desc
+	LabelNames []string
+
+	Parent *PrometheusMetrics
+}
+
+func newStatsAge(name, help string, baseLabels []string) *StatsAgeMetric {
+	return &StatsAgeMetric{
+		Desc: prometheus.NewDesc(
+			metricName(name),
+			help, baseLabels, nil),
+
+		LabelNames: baseLabels,
+	}
+}
+
+func (m *StatsAgeMetric) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.Desc
+}
+
+func (m *StatsAgeMetric) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	for _, item := range m.Parent.Containers {
+		c := item
+
+		if m.Parent.hidden[c.Id] {
+			continue
+		}
+
+		s := getCached(c.Id)
+		if s == nil || s.Read.IsZero() {
+			continue
+		}
+
+		labelValues := m.Parent.labelValues(&c, m.LabelNames)
+
+		if metric, err := prometheus.NewConstMetric(
+			m.Desc, prometheus.GaugeValue, now.Sub(s.Read).Seconds(), labelValues...); err == nil {
+			ch <- metric
+		}
+	}
+}
+
+func (m *StatsAgeMetric) WithParent(pm *PrometheusMetrics) SingleMetric {
+	m.Parent = pm
+	return m
+}
+
+func (m *StatsAgeMetric) Set(c *model.Container, s *model.Stats) {
+	// the age is calculated at collection time
+}
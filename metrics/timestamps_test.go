@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTimestampedGauge(t *testing.T) {
+	desc := prometheus.NewDesc("test_metric", "Test metric", []string{"name"}, nil)
+	read := time.Unix(1500000000, 123000000)
+
+	metric, err := newTimestampedGauge(desc, 42, read, []string{"sample"})
+	if err != nil {
+		t.Fatal("Failed to create the metric:", err)
+	}
+
+	out := &dto.Metric{}
+	if err := metric.Write(out); err != nil {
+		t.Fatal("Failed to write the metric:", err)
+	}
+
+	if out.GetTimestampMs() != 1500000000123 {
+		t.Error("Unexpected timestamp:", out.GetTimestampMs())
+	}
+	if out.GetGauge().GetValue() != 42 {
+		t.Error("Unexpected value:", out.GetGauge().GetValue())
+	}
+}
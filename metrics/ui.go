@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"net/http"
+)
+
+// serveUI serves the live container table page,
+// which is fed by the JSON API and the live stream
+func serveUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(uiPage))
+}
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Container metrics</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em; color: #222; }
+h1 { font-size: 1.3em; }
+#engine span { margin-right: 1.5em; }
+table { border-collapse: collapse; width: 100%; margin-top: 1em; }
+th, td { padding: 4px 8px; text-align: right; border-bottom: 1px solid #ddd; white-space: nowrap; }
+th { cursor: pointer; user-select: none; background: #f4f4f4; }
+th:first-child, td:first-child, th:nth-child(2), td:nth-child(2) { text-align: left; }
+td.image { color: #777; }
+svg { vertical-align: middle; }
+polyline { fill: none; stroke: #3572b0; stroke-width: 1.5; }
+#status { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Container metrics</h1>
+<div id="engine"></div>
+<div id="status">Connecting ...</div>
+<table>
+<thead><tr>
+<th data-key="name">Name</th>
+<th data-key="image">Image</th>
+<th data-key="cpu">CPU %</th>
+<th>CPU history</th>
+<th data-key="memory">Memory</th>
+<th data-key="memoryPercent">Memory %</th>
+<th data-key="rx">Net RX/s</th>
+<th data-key="tx">Net TX/s</th>
+<th data-key="read">Read/s</th>
+<th data-key="written">Write/s</th>
+</tr></thead>
+<tbody id="containers"></tbody>
+</table>
+<script>
+(function () {
+  var historySeconds = 300;
+  var rows = {};
+  var sortKey = "name", sortDesc = false;
+
+  function bytes(value) {
+    var units = ["B", "KiB", "MiB", "GiB", "TiB"], idx = 0;
+    while (value >= 1024 && idx < units.length - 1) { value /= 1024; idx++; }
+    return value.toFixed(idx ? 1 : 0) + " " + units[idx];
+  }
+
+  function rate(current, previous, seconds) {
+    if (!previous || seconds <= 0 || current < previous) { return 0; }
+    return (current - previous) / seconds;
+  }
+
+  function sparkline(points) {
+    if (points.length < 2) { return ""; }
+    var max = Math.max.apply(null, points.map(function (p) { return p.value; }).concat([1]));
+    var first = points[0].time, span = Math.max(points[points.length - 1].time - first, 1);
+    var coords = points.map(function (p) {
+      return ((p.time - first) / span * 100).toFixed(1) + "," + (20 - p.value / max * 18).toFixed(1);
+    });
+    return '<svg width="100" height="20"><polyline points="' + coords.join(" ") + '"/></svg>';
+  }
+
+  function escape(text) {
+    return String(text).replace(/[&<>"]/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;" }[c];
+    });
+  }
+
+  function update(container, stats) {
+    var row = rows[container.id] || { history: [] };
+    row.name = container.name;
+    row.image = container.image;
+    rows[container.id] = row;
+
+    if (!stats) { return; }
+
+    var time = new Date(stats.read).getTime() / 1000 || Date.now() / 1000;
+    var previous = row.stats, seconds = row.time ? time - row.time : 0;
+    if (previous && seconds > 0) {
+      row.rx = rate(stats.network.rx_bytes, previous.network.rx_bytes, seconds);
+      row.tx = rate(stats.network.tx_bytes, previous.network.tx_bytes, seconds);
+      row.read = rate(stats.io.read, previous.io.read, seconds);
+      row.written = rate(stats.io.written, previous.io.written, seconds);
+    } else if (previous) {
+      return;
+    }
+
+    row.stats = stats;
+    row.time = time;
+    row.cpu = stats.cpu.percent;
+    row.memory = stats.memory.usage;
+    row.memoryPercent = stats.memory.percent;
+    row.history.push({ time: time, value: stats.cpu.percent });
+    while (row.history.length && row.history[0].time < time - historySeconds) { row.history.shift(); }
+  }
+
+  function render() {
+    var ids = Object.keys(rows).sort(function (a, b) {
+      var x = rows[a][sortKey], y = rows[b][sortKey];
+      if (x === undefined) { x = ""; }
+      if (y === undefined) { y = ""; }
+      var result = x < y ? -1 : x > y ? 1 : 0;
+      return sortDesc ? -result : result;
+    });
+
+    document.getElementById("containers").innerHTML = ids.map(function (id) {
+      var row = rows[id];
+      return "<tr><td>" + escape(row.name) + '</td><td class="image">' + escape(row.image) + "</td>" +
+        "<td>" + (row.cpu || 0).toFixed(2) + "</td>" +
+        "<td>" + sparkline(row.history) + "</td>" +
+        "<td>" + bytes(row.memory || 0) + "</td>" +
+        "<td>" + (row.memoryPercent || 0).toFixed(2) + "</td>" +
+        "<td>" + bytes(row.rx || 0) + "</td>" +
+        "<td>" + bytes(row.tx || 0) + "</td>" +
+        "<td>" + bytes(row.read || 0) + "</td>" +
+        "<td>" + bytes(row.written || 0) + "</td></tr>";
+    }).join("");
+  }
+
+  function renderEngine(engine) {
+    document.getElementById("engine").innerHTML =
+      "<span>Host: <b>" + escape(engine.host) + "</b></span>" +
+      "<span>Images: <b>" + engine.images + "</b></span>" +
+      "<span>Containers: <b>" + engine.containers + "</b></span>" +
+      "<span>Running: <b>" + engine.containers_running + "</b></span>" +
+      "<span>Paused: <b>" + engine.containers_paused + "</b></span>" +
+      "<span>Stopped: <b>" + engine.containers_stopped + "</b></span>";
+  }
+
+  function load(path, callback) {
+    var request = new XMLHttpRequest();
+    request.onload = function () {
+      if (request.status === 200) { callback(JSON.parse(request.responseText)); }
+    };
+    request.open("GET", path);
+    request.send();
+  }
+
+  Array.prototype.forEach.call(document.querySelectorAll("th[data-key]"), function (th) {
+    th.onclick = function () {
+      var key = th.getAttribute("data-key");
+      sortDesc = sortKey === key ? !sortDesc : key !== "name" && key !== "image";
+      sortKey = key;
+      render();
+    };
+  });
+
+  load("api/v1/containers", function (items) {
+    items.forEach(function (item) { update(item.container, item.stats); });
+    render();
+  });
+  load("api/v1/engine", renderEngine);
+
+  var status = document.getElementById("status");
+  var stream = new EventSource("api/v1/stream");
+  stream.onopen = function () { status.textContent = "Live"; };
+  stream.onerror = function () { status.textContent = "Disconnected, reconnecting ..."; };
+  stream.addEventListener("stats", function (e) {
+    var item = JSON.parse(e.data);
+    update(item.container, item.stats);
+    render();
+  });
+  stream.addEventListener("start", function (e) {
+    update(JSON.parse(e.data).container);
+    render();
+  });
+  stream.addEventListener("stop", function (e) {
+    delete rows[JSON.parse(e.data).container.id];
+    render();
+  });
+  stream.addEventListener("engine", function (e) { renderEngine(JSON.parse(e.data)); });
+})();
+</script>
+</body>
+</html>
+`
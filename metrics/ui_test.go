@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUI(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	serveUI(recorder, httptest.NewRequest("GET", "/", nil))
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `EventSource("api/v1/stream")`) {
+		t.Error("Unexpected response:", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	serveUI(recorder, httptest.NewRequest("GET", "/missing", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Error("Unexpected status:", recorder.Code)
+	}
+}
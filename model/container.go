@@ -1,8 +1,21 @@
 package model
 
 type Container struct {
-	Id     string
-	Name   string
-	Image  string
-	Labels map[string]string
+	Id     string            `json:"id"`
+	Name   string            `json:"name"`
+	Image  string            `json:"image"`
+	Labels map[string]string `json:"labels"`
+	Health string            `json:"health,omitempty"`
+
+	ImageRepository string `json:"image_repository,omitempty"`
+	ImageTag        string `json:"image_tag,omitempty"`
+	ImageDigest     string `json:"image_digest,omitempty"`
+	ImageID         string `json:"image_id,omitempty"`
 }
+
+// the health check states of the containers
+const (
+	Starting  = "starting"
+	Healthy   = "healthy"
+	Unhealthy = "unhealthy"
+)
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Event is a lifecycle event of a container from the Docker engine,
+// like start, die, oom or health_status: unhealthy
+type Event struct {
+	Time      time.Time
+	Action    string
+	Container Container
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Rates are the per-second changes of the cumulative stats
+// between two consecutive samples of a container
+type Rates struct {
+	Seconds float64 `json:"seconds"`
+
+	CpuCores float64 `json:"cpu_cores"`
+
+	IORead    float64 `json:"io_read_bytes"`
+	IOWritten float64 `json:"io_write_bytes"`
+
+	RxBytes   float64 `json:"rx_bytes"`
+	RxPackets float64 `json:"rx_packets"`
+	TxBytes   float64 `json:"tx_bytes"`
+	TxPackets float64 `json:"tx_packets"`
+}
+
+// CalculateRates returns the rates between the previous and the current stats,
+// or nil if the time between them is unknown
+func CalculateRates(previous, current *Stats) *Rates {
+	if previous == nil || current == nil || previous.Read.IsZero() || current.Read.IsZero() {
+		return nil
+	}
+
+	elapsed := current.Read.Sub(previous.Read)
+	if elapsed <= 0 {
+		return nil
+	}
+
+	seconds := elapsed.Seconds()
+
+	rate := func(previous, current uint64) float64 {
+		// counters reset when the container restarts
+		if current < previous {
+			return 0
+		}
+
+		return float64(current-previous) / seconds
+	}
+
+	return &Rates{
+		Seconds: seconds,
+
+		CpuCores: rate(previous.CpuStats.Total, current.CpuStats.Total) / float64(time.Second),
+
+		IORead:    rate(previous.IOStats.Read, current.IOStats.Read),
+		IOWritten: rate(previous.IOStats.Written, current.IOStats.Written),
+
+		RxBytes:   rate(previous.NetworkStats.RxBytes, current.NetworkStats.RxBytes),
+		RxPackets: rate(previous.NetworkStats.RxPackets, current.NetworkStats.RxPackets),
+		TxBytes:   rate(previous.NetworkStats.TxBytes, current.NetworkStats.TxBytes),
+		TxPackets: rate(previous.NetworkStats.TxPackets, current.NetworkStats.TxPackets),
+	}
+}
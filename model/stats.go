@@ -1,51 +1,62 @@
 package model
 
+import "time"
+
 type Stats struct {
-	Id   string
-	Name string
+	Id   string    `json:"id"`
+	Name string    `json:"name"`
+	Read time.Time `json:"read"`
+
+	CpuStats     CpuStats     `json:"cpu"`
+	MemoryStats  MemoryStats  `json:"memory"`
+	IOStats      IOStats      `json:"io"`
+	NetworkStats NetworkStats `json:"network"`
+	PidsStats    PidsStats    `json:"pids"`
 
-	CpuStats     CpuStats
-	MemoryStats  MemoryStats
-	IOStats      IOStats
-	NetworkStats NetworkStats
+	Rates *Rates `json:"rates,omitempty"`
 }
 
 type CpuStats struct {
-	Total   uint64
-	User    uint64
-	System  uint64
-	Percent float64
+	Total   uint64  `json:"total"`
+	User    uint64  `json:"user"`
+	System  uint64  `json:"system"`
+	Percent float64 `json:"percent"`
 }
 
 type MemoryStats struct {
-	Total   uint64
-	Usage   float64
-	Percent float64
+	Total   uint64  `json:"total"`
+	Usage   float64 `json:"usage"`
+	Percent float64 `json:"percent"`
 }
 
 type IOStats struct {
-	Read    uint64
-	Written uint64
+	Read    uint64 `json:"read"`
+	Written uint64 `json:"written"`
 }
 
 type NetworkStats struct {
-	RxBytes   uint64
-	RxPackets uint64
-	RxDropped uint64
-	RxErrors  uint64
-
-	TxBytes   uint64
-	TxPackets uint64
-	TxDropped uint64
-	TxErrors  uint64
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxDropped uint64 `json:"rx_dropped"`
+	RxErrors  uint64 `json:"rx_errors"`
+
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxDropped uint64 `json:"tx_dropped"`
+	TxErrors  uint64 `json:"tx_errors"`
+}
+
+type PidsStats struct {
+	Current uint64 `json:"current"`
+	Limit   uint64 `json:"limit"`
 }
 
 type EngineStats struct {
-	Host string
+	Host string `json:"host"`
 
-	Images            int
-	Containers        int
-	ContainersRunning int
-	ContainersPaused  int
-	ContainersStopped int
+	Images            int `json:"images"`
+	Containers        int `json:"containers"`
+	ContainersRunning int `json:"containers_running"`
+	ContainersPaused  int `json:"containers_paused"`
+	ContainersStopped int `json:"containers_stopped"`
 }
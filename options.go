@@ -0,0 +1,336 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rycus86/container-metrics/alert"
+	"github.com/rycus86/container-metrics/config"
+	"github.com/rycus86/container-metrics/docker"
+	"github.com/rycus86/container-metrics/metrics"
+	"github.com/rycus86/container-metrics/relabel"
+)
+
+type options struct {
+	configFile string
+	dockerHost string
+
+	port     int
+	interval time.Duration
+	timeout  time.Duration
+	labels   string
+	debug    bool
+	verbose  bool
+
+	onScrape    bool
+	scrapeCache time.Duration
+	timestamps  bool
+
+	streamClients int
+	history       time.Duration
+
+	include       string
+	exclude       string
+	optIn         bool
+	dockerFilters string
+	filter        *docker.ContainerFilter
+
+	relabelConfig  string
+	relabelConfigs []relabel.Config
+	relabelRules   []*relabel.Rule
+
+	namespace   string
+	naming      string
+	metricNames string
+	infoLabels  bool
+
+	maxLabelValueLength int
+	labelValueOverflow  string
+	maxLabelKeys        int
+	maxSeries           int
+
+	collectors map[string]bool
+
+	alertRules  string
+	alertConfig *alert.Config
+
+	outputs outputFlags
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	// -c or -config
+	fs.StringVar(&o.configFile, "config", "",
+		"YAML configuration file to read the options from")
+	fs.StringVar(&o.configFile, "c", "",
+		"YAML configuration file to read the options from (shorthand)")
+	fs.StringVar(&o.dockerHost, "docker-host", "",
+		"Docker engine to connect to, instead of the one in DOCKER_HOST")
+	// -p or -port
+	fs.IntVar(&o.port, "port", 8080,
+		"HTTP port to listen on")
+	fs.IntVar(&o.port, "p", 8080,
+		"HTTP port to listen on (shorthand)")
+	// -i or -interval
+	fs.DurationVar(&o.interval, "interval", 5*time.Second,
+		"Interval for reading metrics from the engine")
+	fs.DurationVar(&o.interval, "i", 5*time.Second,
+		"Interval for reading metrics from the engine (shorthand)")
+	// -t or -timeout
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second,
+		"Timeout for calling endpoints on the engine")
+	fs.DurationVar(&o.timeout, "t", 30*time.Second,
+		"Timeout for calling endpoints on the engine (shorthand)")
+	// -l or -labels
+	fs.StringVar(&o.labels, "labels", "",
+		"Labels to keep (comma separated, accepts regex)")
+	fs.StringVar(&o.labels, "l", "",
+		"Labels to keep (comma separated, accepts regex) (shorthand)")
+	// -s or -scrape
+	fs.BoolVar(&o.onScrape, "scrape", false,
+		"Collect metrics when scraped instead of on every interval")
+	fs.BoolVar(&o.onScrape, "s", false,
+		"Collect metrics when scraped instead of on every interval (shorthand)")
+	fs.DurationVar(&o.scrapeCache, "scrape-cache", 2*time.Second,
+		"Time to reuse the metrics collected on scrape for")
+	fs.BoolVar(&o.timestamps, "timestamps", false,
+		"Expose the time the stats were read at with the metrics")
+	fs.IntVar(&o.streamClients, "stream-clients", 16,
+		"Maximum number of clients connected to the live stream")
+	fs.DurationVar(&o.history, "history", 15*time.Minute,
+		"Time to keep the recent stats of the containers in memory for")
+	fs.StringVar(&o.include, "include", "",
+		"Only collect the metrics of the containers matching these patterns (comma separated)")
+	fs.StringVar(&o.exclude, "exclude", "",
+		"Skip the containers matching these patterns (comma separated)")
+	fs.BoolVar(&o.optIn, "opt-in", false,
+		"Only collect the metrics of the containers with the "+docker.EnableLabel+"=true label")
+	fs.StringVar(&o.dockerFilters, "docker-filter", "",
+		"Filters for listing the containers in the Docker API (comma separated key=value pairs)")
+	fs.StringVar(&o.alertRules, "alert-rules", "",
+		"YAML file with the alerting rules and the endpoints to notify")
+	fs.StringVar(&o.relabelConfig, "relabel-config", "",
+		"YAML file with the relabeling rules for the containers")
+	// -d or -debug
+	fs.BoolVar(&o.debug, "debug", false,
+		"Enable debug messages")
+	fs.BoolVar(&o.debug, "d", false,
+		"Enable debug messages (shorthand)")
+	// -v or -verbose
+	fs.BoolVar(&o.verbose, "verbose", false,
+		"Enable verbose messages - assumes debug")
+	fs.BoolVar(&o.verbose, "v", false,
+		"Enable verbose messages - assumes debug (shorthand)")
+
+	fs.StringVar(&o.namespace, "namespace", "cntm",
+		"Namespace to prefix the names of the metrics with")
+	fs.StringVar(&o.naming, "naming", metrics.NamingDefault,
+		"Naming profile for the metrics and their labels: "+metrics.NamingDefault+" or "+metrics.NamingCAdvisor)
+	fs.StringVar(&o.metricNames, "metric-name", "",
+		"Names to expose the metrics with, by their names without the namespace (comma separated name=new_name pairs)")
+
+	fs.BoolVar(&o.infoLabels, "info-labels", false,
+		"Put the container labels only on the container info metric, instead of every metric")
+
+	fs.IntVar(&o.maxLabelValueLength, "max-label-value-length", 0,
+		"Maximum length of the container label values, 0 for no limit")
+	fs.StringVar(&o.labelValueOverflow, "label-value-overflow", "truncate",
+		"How to shorten the label values over the limit: truncate or hash")
+	fs.IntVar(&o.maxLabelKeys, "max-label-keys", 0,
+		"Maximum number of distinct container labels, 0 for no limit")
+	fs.IntVar(&o.maxSeries, "max-series", 0,
+		"Maximum number of series for the containers, 0 for no limit")
+
+	o.collectors = map[string]bool{}
+	registerCollectors(fs, o.collectors)
+
+	o.outputs.register(fs)
+}
+
+const environmentPrefix = "CNTM"
+
+// loadOptions parses the command line arguments, the environment variables,
+// and the configuration file if given, in the order of precedence
+func loadOptions(args []string) (*options, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	opts.register(fs)
+
+	// the location of the configuration file can come from the arguments or the environment
+	if err := parseArgsAndEnvironment(fs, args); err != nil {
+		return nil, err
+	}
+
+	if opts.configFile != "" {
+		values, err := config.Load(opts.configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := config.Apply(fs, values, "config", "c"); err != nil {
+			return nil, err
+		}
+
+		// override the values from the file
+		if err := parseArgsAndEnvironment(fs, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	filter, err := docker.NewContainerFilter(
+		docker.SplitPatterns(opts.include), docker.SplitPatterns(opts.exclude), opts.optIn, splitList(opts.dockerFilters))
+	if err != nil {
+		return nil, err
+	}
+
+	opts.filter = filter
+
+	if opts.relabelConfig != "" {
+		configs, err := relabel.Load(opts.relabelConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err := relabel.Compile(configs, func(label string) bool {
+			return metrics.ReservedLabelName(opts.naming, label)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		opts.relabelConfigs = configs
+		opts.relabelRules = rules
+	}
+
+	if opts.alertRules != "" {
+		alertConfig, err := alert.Load(opts.alertRules)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.alertConfig = alertConfig
+	}
+
+	return opts, nil
+}
+
+// parseArgsAndEnvironment sets the flags from the environment,
+// then from the command line arguments taking precedence
+func parseArgsAndEnvironment(fs *flag.FlagSet, args []string) error {
+	if err := config.ApplyEnvironment(fs, environmentPrefix, os.LookupEnv); err != nil {
+		return err
+	}
+
+	return fs.Parse(args)
+}
+
+var (
+	validNamespace  = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	validMetricName = regexp.MustCompile("^[a-zA-Z_:][a-zA-Z0-9_:]*$")
+)
+
+func (o *options) validate() error {
+	if o.interval <= 0 {
+		return fmt.Errorf("the interval has to be positive: %s", o.interval)
+	}
+
+	if o.timeout <= 0 {
+		return fmt.Errorf("the timeout has to be positive: %s", o.timeout)
+	}
+
+	if o.port <= 0 || o.port > 65535 {
+		return fmt.Errorf("invalid HTTP port: %d", o.port)
+	}
+
+	if o.namespace != "" && !validNamespace.MatchString(o.namespace) {
+		return fmt.Errorf("invalid namespace: %s", o.namespace)
+	}
+
+	if o.naming != metrics.NamingDefault && o.naming != metrics.NamingCAdvisor {
+		return fmt.Errorf("unknown naming profile: %s", o.naming)
+	}
+
+	for _, item := range splitList(o.metricNames) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid metric name override: %s", item)
+		}
+
+		for _, name := range parts {
+			if !validMetricName.MatchString(strings.TrimSpace(name)) {
+				return fmt.Errorf("invalid metric name override: %s", item)
+			}
+		}
+	}
+
+	if o.maxLabelValueLength < 0 || o.maxLabelKeys < 0 || o.maxSeries < 0 {
+		return fmt.Errorf("the limits cannot be negative")
+	}
+
+	if o.labelValueOverflow != "truncate" && o.labelValueOverflow != "hash" {
+		return fmt.Errorf("invalid label value overflow: %s", o.labelValueOverflow)
+	}
+
+	if enabled, exists := o.collectors["info"]; o.infoLabels && exists && !enabled {
+		return fmt.Errorf("the info collector is required for the labels on the info metric")
+	}
+
+	return nil
+}
+
+// splitList splits the comma separated items, skipping the empty ones
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// containersChanged returns true if the options selecting or labeling the containers,
+// or naming their metrics are different
+func (o *options) containersChanged(other *options) bool {
+	return o.labels != other.labels ||
+		o.namespace != other.namespace || o.naming != other.naming || o.metricNames != other.metricNames ||
+		o.infoLabels != other.infoLabels ||
+		o.limits() != other.limits() ||
+		o.include != other.include || o.exclude != other.exclude ||
+		o.optIn != other.optIn || o.dockerFilters != other.dockerFilters ||
+		!reflect.DeepEqual(o.relabelConfigs, other.relabelConfigs)
+}
+
+func (o *options) limits() metrics.Limits {
+	return metrics.Limits{
+		MaxLabelValueLength: o.maxLabelValueLength,
+		HashLabelValues:     o.labelValueOverflow == "hash",
+		MaxLabelKeys:        o.maxLabelKeys,
+		MaxSeries:           o.maxSeries,
+	}
+}
+
+func (o *options) labelFilters() []string {
+	return strings.Split(o.labels, ",")
+}
+
+// historySize returns the number of samples to keep for the history,
+// which are collected at most once per scrape cache when collecting on scrape
+func (o *options) historySize() int {
+	if o.onScrape && o.scrapeCache > 0 {
+		return int(o.history / o.scrapeCache)
+	}
+
+	return int(o.history / o.interval)
+}
@@ -0,0 +1,58 @@
+package output
+
+import "sync"
+
+// lineBuffer keeps a bounded number of unsent lines,
+// dropping the oldest ones first when it overflows
+type lineBuffer struct {
+	lock    sync.Mutex
+	lines   []string
+	max     int
+	dropped int
+}
+
+// add appends the lines and returns the number of lines buffered
+func (b *lineBuffer) add(lines ...string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lines = append(b.lines, lines...)
+	b.trim()
+
+	return len(b.lines)
+}
+
+// take removes at most size lines from the front of the buffer,
+// and also returns the number of lines dropped since the last call
+func (b *lineBuffer) take(size int) ([]string, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if size <= 0 || size > len(b.lines) {
+		size = len(b.lines)
+	}
+
+	taken := b.lines[0:size:size]
+	b.lines = b.lines[size:]
+
+	dropped := b.dropped
+	b.dropped = 0
+
+	return taken, dropped
+}
+
+// requeue puts the lines that failed to send back in front of the ones added since
+func (b *lineBuffer) requeue(lines []string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lines = append(lines[0:len(lines):len(lines)], b.lines...)
+	b.trim()
+}
+
+func (b *lineBuffer) trim() {
+	if overflow := len(b.lines) - b.max; b.max > 0 && overflow > 0 {
+		b.lines = b.lines[overflow:]
+		b.dropped += overflow
+	}
+}
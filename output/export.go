@@ -0,0 +1,135 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rycus86/container-metrics/metrics"
+)
+
+// Export writes the records stored since the given time
+// in the OpenMetrics text format, or as CSV rows
+func Export(w io.Writer, directory string, since time.Time, format string) error {
+	switch format {
+	case "openmetrics":
+		return exportOpenMetrics(w, directory, since)
+	case "csv":
+		return exportCSV(w, directory, since)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// exportOpenMetrics writes each metric family in a separate pass
+// over the segments, as the samples of a family have to be kept together
+// for backfilling, and the segments could be too large to fit in memory
+func exportOpenMetrics(w io.Writer, directory string, since time.Time) error {
+	out := bufio.NewWriter(w)
+
+	for _, field := range containerFields {
+		name := defaultPrefix + "_" + field.Group + "_" + field.Name
+		fmt.Fprintf(out, "# TYPE %s gauge\n", name)
+
+		err := scanStore(directory, since, func(r *storeRecord) {
+			if r.Stats != nil && r.Container != nil {
+				labels := containerTags(r.Container, r.Host)
+				writeOpenMetricsSample(out, name, labels, field.Value(r.Stats), r.Time)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, field := range engineFields {
+		name := defaultPrefix + "_" + field.Group + "_" + field.Name
+		fmt.Fprintf(out, "# TYPE %s gauge\n", name)
+
+		err := scanStore(directory, since, func(r *storeRecord) {
+			if r.Engine != nil {
+				labels := map[string]string{"engine_host": r.Engine.Host}
+				writeOpenMetricsSample(out, name, labels, field.Value(r.Engine), r.Time)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprint(out, "# EOF\n")
+
+	return out.Flush()
+}
+
+func writeOpenMetricsSample(w io.Writer, name string, labels map[string]string, value float64, timestamp time.Time) {
+	names := make([]string, 0, len(labels))
+	values := map[string]string{}
+
+	for key, value := range labels {
+		if value == "" {
+			continue
+		}
+
+		sanitized := metrics.SanitizeName(key)
+		if _, exists := values[sanitized]; !exists {
+			names = append(names, sanitized)
+		}
+
+		values[sanitized] = value
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for idx, key := range names {
+		pairs[idx] = key + `="` + escapeLabelValue(values[key]) + `"`
+	}
+
+	seconds := strconv.FormatFloat(float64(timestamp.UnixNano())/float64(time.Second), 'f', 3, 64)
+
+	fmt.Fprintf(w, "%s{%s} %s %s\n", name, strings.Join(pairs, ","), formatFloat(value), seconds)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func exportCSV(w io.Writer, directory string, since time.Time) error {
+	out := csv.NewWriter(w)
+
+	header := []string{"time", "engine_host", "container_id", "container_name", "container_image"}
+	for _, field := range containerFields {
+		header = append(header, field.Group+"_"+field.Name)
+	}
+
+	out.Write(header)
+
+	err := scanStore(directory, since, func(r *storeRecord) {
+		if r.Stats == nil || r.Container == nil {
+			return
+		}
+
+		row := []string{
+			r.Time.UTC().Format(time.RFC3339Nano), r.Host,
+			r.Container.Id, r.Container.Name, r.Container.Image,
+		}
+
+		for _, field := range containerFields {
+			row = append(row, formatFloat(field.Value(r.Stats)))
+		}
+
+		out.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,512 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	fileTimeLayout = "20060102T150405.000000000"
+	labelPrefix    = "label_"
+)
+
+type FileConfig struct {
+	Path   string
+	Format string
+	Labels []string
+
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+
+	Interval time.Duration
+}
+
+// File writes every sample as a CSV row or a JSON line to a file or the standard output,
+// rotating the files when they reach the maximum size or age
+type File struct {
+	config  FileConfig
+	columns []fileColumn
+	host    engineHost
+
+	lock    sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64
+	created time.Time
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// fileColumn is a value of the stats, named after the JSON fields
+type fileColumn struct {
+	name  string
+	value func(*model.Stats) interface{}
+}
+
+func NewFile(config FileConfig) (*File, error) {
+	if config.Format != "csv" && config.Format != "json" {
+		return nil, fmt.Errorf("unknown file output format: %s", config.Format)
+	}
+
+	f := &File{
+		config:  config,
+		columns: statsColumns(),
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	go f.run()
+
+	return f, nil
+}
+
+func (f *File) isStdout() bool {
+	return f.config.Path == "-"
+}
+
+// open opens the output file, expects the lock to be held
+func (f *File) open() error {
+	if f.isStdout() {
+		f.writer = bufio.NewWriter(os.Stdout)
+	} else {
+		file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		f.file = file
+		f.writer = bufio.NewWriter(file)
+		f.size = info.Size()
+		f.created = info.ModTime()
+
+		// the file is written on every interval, so its age comes from its first record
+		if t, ok := f.firstRecordTime(); ok {
+			f.created = t
+		}
+	}
+
+	if f.config.Format == "csv" && f.size == 0 {
+		f.write(f.csvHeader())
+	}
+
+	return nil
+}
+
+// firstRecordTime returns the time of the first sample in the output file
+func (f *File) firstRecordTime() (time.Time, bool) {
+	file, err := os.Open(f.config.Path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	lines := bufio.NewScanner(file)
+	lines.Buffer(nil, 1024*1024)
+
+	if f.config.Format == "csv" && !lines.Scan() {
+		// skipping the header
+		return time.Time{}, false
+	}
+
+	if !lines.Scan() {
+		return time.Time{}, false
+	}
+
+	var value string
+
+	if f.config.Format == "csv" {
+		record, err := csv.NewReader(strings.NewReader(lines.Text())).Read()
+		if err != nil || len(record) == 0 {
+			return time.Time{}, false
+		}
+
+		value = record[0]
+	} else {
+		var record struct {
+			Time string `json:"time"`
+		}
+
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil {
+			return time.Time{}, false
+		}
+
+		value = record.Time
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func (f *File) OnStats(c *model.Container, s *model.Stats) {
+	var line []byte
+
+	if f.config.Format == "csv" {
+		line = f.csvRow(c, s, f.host.get())
+	} else {
+		line = f.jsonLine(c, s, f.host.get())
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.shouldRotate(len(line)) {
+		f.rotate()
+	}
+
+	if f.writer != nil {
+		f.write(line)
+	}
+}
+
+func (f *File) OnEngineStats(s *model.EngineStats) {
+	f.host.set(s.Host)
+}
+
+// write appends the line to the file, expects the lock to be held
+func (f *File) write(line []byte) {
+	n, err := f.writer.Write(line)
+	f.size += int64(n)
+
+	if err != nil {
+		log.Println("Failed to write to the output file", err)
+	}
+}
+
+func (f *File) shouldRotate(next int) bool {
+	if f.isStdout() || f.file == nil {
+		return false
+	}
+
+	if f.config.MaxSize > 0 && f.size > 0 && f.size+int64(next) > f.config.MaxSize {
+		return true
+	}
+
+	return f.config.MaxAge > 0 && time.Since(f.created) > f.config.MaxAge
+}
+
+// rotate renames the current file with a timestamp and opens a new one,
+// expects the lock to be held
+func (f *File) rotate() {
+	f.closeFile()
+
+	rotated := f.rotatedPath(time.Now().UTC())
+	for {
+		// two rotations at the same time must not overwrite each other
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+
+		t, _ := f.rotatedTime(rotated)
+		rotated = f.rotatedPath(t.Add(time.Nanosecond))
+	}
+
+	if err := os.Rename(f.config.Path, rotated); err != nil {
+		log.Println("Failed to rotate the output file", err)
+	} else if logging.IsDebugEnabled() {
+		log.Println("Rotated the output file to", rotated)
+	}
+
+	f.removeBackups()
+
+	if err := f.open(); err != nil {
+		log.Println("Failed to open the output file", err)
+	}
+}
+
+// rotatedPath returns the name of the file rotated at the given time
+func (f *File) rotatedPath(t time.Time) string {
+	extension := filepath.Ext(f.config.Path)
+	return strings.TrimSuffix(f.config.Path, extension) + "-" + t.Format(fileTimeLayout) + extension
+}
+
+// rotatedTime returns the time of the rotation from the name of a rotated file,
+// and false for the other files
+func (f *File) rotatedTime(path string) (time.Time, bool) {
+	extension := filepath.Ext(f.config.Path)
+	prefix := strings.TrimSuffix(f.config.Path, extension) + "-"
+
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, extension) {
+		return time.Time{}, false
+	}
+
+	// parsing accepts any fraction of the seconds, like the millisecond ones of the older versions
+	t, err := time.Parse("20060102T150405", strings.TrimSuffix(strings.TrimPrefix(path, prefix), extension))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// removeBackups deletes the oldest rotated files over the maximum number of backups
+func (f *File) removeBackups() {
+	if f.config.MaxBackups <= 0 {
+		return
+	}
+
+	extension := filepath.Ext(f.config.Path)
+	matches, err := filepath.Glob(strings.TrimSuffix(f.config.Path, extension) + "-*" + extension)
+	if err != nil {
+		log.Println("Failed to list the rotated output files", err)
+		return
+	}
+
+	// only the files named with the time of the rotation are backups
+	var backups []string
+	for _, path := range matches {
+		if _, ok := f.rotatedTime(path); ok {
+			backups = append(backups, path)
+		}
+	}
+
+	// the timestamps in the names sort in chronological order
+	sort.Strings(backups)
+
+	for len(backups) > f.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			log.Println("Failed to delete the rotated output file", err)
+		}
+
+		backups = backups[1:]
+	}
+}
+
+// closeFile flushes and closes the current file, expects the lock to be held
+func (f *File) closeFile() {
+	if f.writer != nil {
+		if err := f.writer.Flush(); err != nil {
+			log.Println("Failed to flush the output file", err)
+		}
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+
+	f.file = nil
+	f.writer = nil
+	f.size = 0
+}
+
+func (f *File) flush() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writer != nil {
+		if err := f.writer.Flush(); err != nil {
+			log.Println("Failed to flush the output file", err)
+		}
+	}
+}
+
+func (f *File) run() {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.flush()
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *File) Close() error {
+	close(f.done)
+	<-f.stopped
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closeFile()
+	return nil
+}
+
+func (f *File) csvHeader() []byte {
+	header := []string{"time", "engine_host", "container_id", "container_name", "container_image"}
+
+	for _, column := range f.columns {
+		header = append(header, column.name)
+	}
+
+	for _, label := range f.config.Labels {
+		header = append(header, labelPrefix+label)
+	}
+
+	return csvLine(header)
+}
+
+func (f *File) csvRow(c *model.Container, s *model.Stats, host string) []byte {
+	row := []string{sampleTime(s).UTC().Format(time.RFC3339Nano), host, c.Id, c.Name, c.Image}
+
+	for _, column := range f.columns {
+		row = append(row, formatColumn(column.value(s)))
+	}
+
+	for _, label := range f.config.Labels {
+		row = append(row, c.Labels[label])
+	}
+
+	return csvLine(row)
+}
+
+func csvLine(values []string) []byte {
+	buf := &strings.Builder{}
+
+	w := csv.NewWriter(buf)
+	w.Write(values)
+	w.Flush()
+
+	return []byte(buf.String())
+}
+
+// jsonLine encodes the sample as a flat JSON object,
+// with the fields in the same order as the CSV columns
+func (f *File) jsonLine(c *model.Container, s *model.Stats, host string) []byte {
+	buf := &strings.Builder{}
+
+	add := func(name string, value interface{}) {
+		if buf.Len() == 0 {
+			buf.WriteString("{")
+		} else {
+			buf.WriteString(",")
+		}
+
+		key, _ := json.Marshal(name)
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			// values like NaN are not valid in JSON
+			encoded = []byte("null")
+		}
+
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(encoded)
+	}
+
+	add("time", sampleTime(s).UTC().Format(time.RFC3339Nano))
+	add("engine_host", host)
+	add("container_id", c.Id)
+	add("container_name", c.Name)
+	add("container_image", c.Image)
+
+	for _, column := range f.columns {
+		add(column.name, column.value(s))
+	}
+
+	labels := make([]string, 0, len(c.Labels))
+	for label := range c.Labels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		add(labelPrefix+label, c.Labels[label])
+	}
+
+	buf.WriteString("}\n")
+
+	return []byte(buf.String())
+}
+
+func formatColumn(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return formatFloat(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// statsColumns lists the nested fields of the stats,
+// named after their JSON names, like cpu_percent or network_rx_bytes
+func statsColumns() []fileColumn {
+	var columns []fileColumn
+
+	statsType := reflect.TypeOf(model.Stats{})
+
+	for i := 0; i < statsType.NumField(); i++ {
+		group := statsType.Field(i)
+
+		groupType := group.Type
+		if groupType.Kind() == reflect.Ptr {
+			groupType = groupType.Elem()
+		}
+
+		// the identifiers and the time have their own columns
+		if groupType.Kind() != reflect.Struct || groupType == reflect.TypeOf(time.Time{}) {
+			continue
+		}
+
+		for j := 0; j < groupType.NumField(); j++ {
+			groupIndex, fieldIndex := i, j
+
+			columns = append(columns, fileColumn{
+				name: jsonName(group) + "_" + jsonName(groupType.Field(j)),
+				value: func(s *model.Stats) interface{} {
+					value := reflect.ValueOf(s).Elem().Field(groupIndex)
+
+					if value.Kind() == reflect.Ptr {
+						if value.IsNil() {
+							return nil
+						}
+
+						value = value.Elem()
+					}
+
+					return value.Field(fieldIndex).Interface()
+				},
+			})
+		}
+	}
+
+	return columns
+}
+
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+
+	return field.Name
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestFileCSV(t *testing.T) {
+	directory, err := ioutil.TempDir("", "cntm-file")
+	if err != nil {
+		t.Fatal("Failed to create a temporary directory:", err)
+	}
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "samples.csv")
+
+	file, err := NewFile(FileConfig{
+		Path: path, Format: "csv", Labels: []string{"tier"},
+		MaxSize: 2048, MaxBackups: 1, Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	file.OnEngineStats(&model.EngineStats{Host: "pi-01"})
+
+	container := &model.Container{Id: "abcd", Name: "web", Image: "nginx", Labels: map[string]string{"tier": "front"}}
+	for idx := 0; idx < 20; idx++ {
+		file.OnStats(container, &model.Stats{
+			Read:         time.Unix(1500000000, 0),
+			CpuStats:     model.CpuStats{Percent: 12.5},
+			NetworkStats: model.NetworkStats{RxBytes: 1024},
+		})
+	}
+
+	file.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(directory, "samples-*.csv"))
+	if len(rotated) != 1 {
+		t.Error("Unexpected rotated files:", rotated)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Failed to read the output:", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+
+	header := strings.Split(lines[0], ",")
+	if header[0] != "time" || header[len(header)-1] != "label_tier" {
+		t.Error("Unexpected header:", lines[0])
+	}
+
+	for _, column := range []string{"cpu_percent", "memory_usage", "io_read", "network_rx_bytes", "rates_cpu_cores"} {
+		if !strings.Contains(lines[0], ","+column+",") {
+			t.Error("Missing column:", column)
+		}
+	}
+
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], "2017-07-14T02:40:00Z,pi-01,abcd,web,nginx,") ||
+		!strings.HasSuffix(lines[1], ",front") {
+		t.Error("Unexpected rows:", lines[1:])
+	}
+
+	if created, ok := file.firstRecordTime(); !ok || !created.Equal(time.Unix(1500000000, 0)) {
+		t.Error("Unexpected time of the first record:", created, ok)
+	}
+}
+
+func TestFileJSON(t *testing.T) {
+	file := &File{config: FileConfig{Format: "json"}, columns: statsColumns()}
+
+	line := file.jsonLine(
+		&model.Container{Name: "web", Labels: map[string]string{"tier": "front"}},
+		&model.Stats{CpuStats: model.CpuStats{Percent: 12.5}, Rates: &model.Rates{RxBytes: 100}},
+		"pi-01")
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatal("Invalid JSON line:", string(line), err)
+	}
+
+	if decoded["cpu_percent"] != 12.5 || decoded["rates_rx_bytes"] != 100.0 ||
+		decoded["label_tier"] != "front" || decoded["engine_host"] != "pi-01" {
+		t.Error("Unexpected JSON line:", string(line))
+	}
+}
+
+func TestFileBackups(t *testing.T) {
+	directory, err := ioutil.TempDir("", "cntm-file")
+	if err != nil {
+		t.Fatal("Failed to create a temporary directory:", err)
+	}
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "stats.json")
+
+	for _, name := range []string{"stats-old.json", "stats-20170714T024000.123.json"} {
+		if err := ioutil.WriteFile(filepath.Join(directory, name), []byte("{}\n"), 0644); err != nil {
+			t.Fatal("Failed to create a file:", err)
+		}
+	}
+
+	// an existing file keeps the age of its first record across restarts,
+	// even though it was written just before the restart
+	first := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	latest := time.Now().UTC().Format(time.RFC3339Nano)
+
+	content := `{"time":"` + first + `","container_id":"abcd"}` + "\n" + `{"time":"` + latest + `","container_id":"abcd"}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Failed to create the file:", err)
+	}
+
+	file, err := NewFile(FileConfig{
+		Path: path, Format: "json", MaxAge: time.Hour, MaxBackups: 2, Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	file.OnStats(&model.Container{Id: "abcd"}, &model.Stats{})
+
+	if rotated, _ := filepath.Glob(filepath.Join(directory, "stats-2*.json")); len(rotated) != 2 {
+		t.Error("Expected the old file to be rotated:", rotated)
+	}
+
+	// quick rotations keep every file
+	file.lock.Lock()
+	file.rotate()
+	file.rotate()
+	file.lock.Unlock()
+
+	file.Close()
+
+	if _, err := os.Stat(filepath.Join(directory, "stats-old.json")); err != nil {
+		t.Error("Expected the unrelated file to be kept:", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "stats-20170714T024000.123.json")); !os.IsNotExist(err) {
+		t.Error("Expected the oldest backup to be removed:", err)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(directory, "stats-2*.json"))
+	if len(rotated) != 2 {
+		t.Error("Unexpected rotated files:", rotated)
+	}
+}
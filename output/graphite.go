@@ -0,0 +1,275 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/metrics"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	DefaultGraphiteTemplate       = "cntm.{engine_host}.{container_name}.{group}.{metric}"
+	DefaultGraphiteEngineTemplate = "cntm.{engine_host}.{group}.{metric}"
+
+	maxUDPPacketSize = 1400
+)
+
+var templatePlaceholder = regexp.MustCompile(`\{([^}]+)\}`)
+
+type GraphiteConfig struct {
+	Address        string
+	Protocol       string
+	Template       string
+	EngineTemplate string
+
+	MaxBuffered int
+	Interval    time.Duration
+	Timeout     time.Duration
+}
+
+// Graphite sends the stats in the Carbon plaintext protocol on every interval,
+// reconnecting and keeping a bounded buffer of unsent lines on failures
+type Graphite struct {
+	config GraphiteConfig
+	host   engineHost
+	buffer lineBuffer
+	conn   net.Conn
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewGraphite(config GraphiteConfig) (*Graphite, error) {
+	if config.Protocol == "" {
+		config.Protocol = "tcp"
+	}
+	if config.Protocol != "tcp" && config.Protocol != "udp" {
+		return nil, fmt.Errorf("invalid Graphite protocol: %s", config.Protocol)
+	}
+	if _, _, err := net.SplitHostPort(config.Address); err != nil {
+		return nil, err
+	}
+
+	if config.Template == "" {
+		config.Template = DefaultGraphiteTemplate
+	}
+	if config.EngineTemplate == "" {
+		config.EngineTemplate = DefaultGraphiteEngineTemplate
+	}
+
+	// the fields would all be written to the same path otherwise
+	for _, template := range []string{config.Template, config.EngineTemplate} {
+		if !strings.Contains(template, "{metric}") {
+			return nil, fmt.Errorf("the Graphite template has to contain {metric}: %s", template)
+		}
+	}
+
+	g := &Graphite{
+		config: config,
+		buffer: lineBuffer{max: config.MaxBuffered},
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go g.run()
+
+	return g, nil
+}
+
+func (g *Graphite) OnStats(c *model.Container, s *model.Stats) {
+	vars := containerTags(c, g.host.get())
+	vars["container_id"] = c.Id
+
+	timestamp := sampleTime(s).Unix()
+
+	lines := make([]string, len(containerFields))
+	for idx, field := range containerFields {
+		vars["group"] = field.Group
+		vars["metric"] = field.Name
+
+		lines[idx] = graphiteLine(renderPath(g.config.Template, vars), field.Value(s), timestamp)
+	}
+
+	if s.Rates != nil {
+		for _, field := range rateFields {
+			vars["group"] = field.Group
+			vars["metric"] = field.Name
+
+			lines = append(lines, graphiteLine(renderPath(g.config.Template, vars), field.Value(s.Rates), timestamp))
+		}
+	}
+
+	g.buffer.add(lines...)
+}
+
+func (g *Graphite) OnEngineStats(s *model.EngineStats) {
+	g.host.set(s.Host)
+
+	vars := map[string]string{"engine_host": s.Host}
+	timestamp := time.Now().Unix()
+
+	lines := make([]string, len(engineFields))
+	for idx, field := range engineFields {
+		vars["group"] = field.Group
+		vars["metric"] = field.Name
+
+		lines[idx] = graphiteLine(renderPath(g.config.EngineTemplate, vars), field.Value(s), timestamp)
+	}
+
+	g.buffer.add(lines...)
+}
+
+func (g *Graphite) run() {
+	defer close(g.stopped)
+
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.flush()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *Graphite) flush() {
+	lines, dropped := g.buffer.take(0)
+	if len(lines) == 0 {
+		return
+	}
+
+	if dropped > 0 {
+		log.Println("Dropped", dropped, "lines while Graphite was unavailable")
+	}
+
+	if sent, err := g.send(lines); err != nil {
+		log.Println("Failed to send metrics to Graphite", err)
+
+		if g.conn != nil {
+			g.conn.Close()
+			g.conn = nil
+		}
+
+		// only the lines not written completely are sent again
+		g.buffer.requeue(lines[sent:])
+		return
+	}
+
+	if logging.IsVerboseEnabled() {
+		log.Println("Sent", len(lines), "lines to Graphite")
+	}
+}
+
+// send writes the lines, and returns the number of lines written completely
+func (g *Graphite) send(lines []string) (int, error) {
+	if g.conn == nil {
+		conn, err := net.DialTimeout(g.config.Protocol, g.config.Address, g.config.Timeout)
+		if err != nil {
+			return 0, err
+		}
+
+		g.conn = conn
+	}
+
+	if g.config.Timeout > 0 {
+		g.conn.SetWriteDeadline(time.Now().Add(g.config.Timeout))
+	}
+
+	if g.config.Protocol == "udp" {
+		// send as many lines in a single datagram as possible
+		return writePackets(g.conn, lines, maxUDPPacketSize)
+	}
+
+	buf := bytes.Buffer{}
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+
+	written, err := g.conn.Write(buf.Bytes())
+	if err == nil {
+		return len(lines), nil
+	}
+
+	sent := 0
+	for _, line := range lines {
+		if written < len(line) {
+			break
+		}
+
+		written -= len(line)
+		sent++
+	}
+
+	return sent, err
+}
+
+func (g *Graphite) Close() error {
+	close(g.done)
+	<-g.stopped
+
+	g.flush()
+
+	if g.conn != nil {
+		return g.conn.Close()
+	}
+
+	return nil
+}
+
+// writePackets packs the lines into datagrams of at most maxSize bytes,
+// and returns the number of lines in the datagrams sent
+func writePackets(conn net.Conn, lines []string, maxSize int) (int, error) {
+	packet := bytes.Buffer{}
+	sent, packed := 0, 0
+
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line) > maxSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return sent, err
+			}
+
+			sent += packed
+			packed = 0
+			packet.Reset()
+		}
+
+		packet.WriteString(line)
+		packed++
+	}
+
+	if packet.Len() > 0 {
+		if _, err := conn.Write(packet.Bytes()); err != nil {
+			return sent, err
+		}
+	}
+
+	return len(lines), nil
+}
+
+// renderPath replaces the {placeholders} in the template with the sanitized values
+func renderPath(template string, vars map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value := vars[placeholder[1:len(placeholder)-1]]
+		if value == "" {
+			return "unknown"
+		}
+
+		return metrics.SanitizeName(value)
+	})
+}
+
+func graphiteLine(path string, value float64, timestamp int64) string {
+	return path + " " + strconv.FormatFloat(value, 'f', -1, 64) + " " + strconv.FormatInt(timestamp, 10) + "\n"
+}
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestGraphiteSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var lines []string
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		received <- lines
+	}()
+
+	graphite, err := NewGraphite(GraphiteConfig{
+		Address:  listener.Addr().String(),
+		Interval: time.Hour,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	graphite.OnEngineStats(&model.EngineStats{Host: "pi.local", Containers: 2})
+	graphite.OnStats(&model.Container{Id: "abcd", Name: "web-1"}, &model.Stats{
+		Read:        time.Unix(1500000000, 0),
+		MemoryStats: model.MemoryStats{Percent: 42.5},
+	})
+
+	graphite.Close()
+
+	lines := <-received
+
+	if len(lines) != len(engineFields)+len(containerFields) {
+		t.Fatal("Unexpected number of lines:", len(lines))
+	}
+
+	if !strings.HasPrefix(lines[1], "cntm.pi_local.engine.num_containers 2 ") {
+		t.Error("Unexpected engine line:", lines[1])
+	}
+
+	found := false
+	for _, line := range lines {
+		if line == "cntm.pi_local.web_1.memory.usage_percent 42.5 1500000000" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("Expected line not found in:", lines)
+	}
+}
+
+func TestRenderPath(t *testing.T) {
+	path := renderPath("prefix.{engine_host}.{com.example.app}.{missing}", map[string]string{
+		"engine_host":     "host-1",
+		"com.example.app": "my app",
+	})
+
+	if path != "prefix.host_1.my_app.unknown" {
+		t.Error("Unexpected path:", path)
+	}
+}
+
+func TestGraphiteTemplateWithoutMetric(t *testing.T) {
+	_, err := NewGraphite(GraphiteConfig{
+		Address:  "127.0.0.1:2003",
+		Template: "cntm.{engine_host}.{container_name}.cpu.percent",
+		Interval: time.Hour,
+	})
+
+	if err == nil {
+		t.Error("Expected an error for a template without {metric}")
+	}
+}
+
+// partialConn accepts only a limited number of bytes, then fails
+type partialConn struct {
+	net.Conn
+
+	accepted int
+}
+
+func (c *partialConn) Write(b []byte) (int, error) {
+	if len(b) > c.accepted {
+		return c.accepted, errors.New("connection reset")
+	}
+
+	return len(b), nil
+}
+
+func (c *partialConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *partialConn) Close() error                       { return nil }
+
+func TestGraphitePartialWrite(t *testing.T) {
+	graphite, err := NewGraphite(GraphiteConfig{Address: "127.0.0.1:2003", Interval: time.Hour})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+	defer close(graphite.done)
+
+	lines := []string{"a.b 1 1500000000\n", "a.c 2 1500000000\n", "a.d 3 1500000000\n"}
+
+	// the first line and a part of the second one are written
+	graphite.conn = &partialConn{accepted: len(lines[0]) + 4}
+	graphite.buffer.add(lines...)
+	graphite.flush()
+
+	remaining, _ := graphite.buffer.take(0)
+	if len(remaining) != 2 || remaining[0] != lines[1] || remaining[1] != lines[2] {
+		t.Errorf("Unexpected lines to send again: %q", remaining)
+	}
+}
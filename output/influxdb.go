@@ -0,0 +1,303 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+type InfluxDBConfig struct {
+	URL string
+
+	// InfluxDB 1.x settings
+	Database        string
+	RetentionPolicy string
+	Username        string
+	Password        string
+
+	// InfluxDB 2.x settings
+	Org    string
+	Bucket string
+	Token  string
+
+	BatchSize   int
+	MaxBuffered int
+	Interval    time.Duration
+	Timeout     time.Duration
+}
+
+// InfluxDB writes the stats in line protocol to InfluxDB over HTTP,
+// keeping a bounded buffer of unsent lines while the server is unavailable
+type InfluxDB struct {
+	config   InfluxDBConfig
+	writeURL string
+	client   *http.Client
+	host     engineHost
+	buffer   lineBuffer
+
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewInfluxDB(config InfluxDBConfig) (*InfluxDB, error) {
+	writeURL, err := influxWriteURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.MaxBuffered < config.BatchSize {
+		config.MaxBuffered = config.BatchSize
+	}
+
+	i := &InfluxDB{
+		config:   config,
+		writeURL: writeURL,
+		client:   &http.Client{Timeout: config.Timeout},
+		buffer:   lineBuffer{max: config.MaxBuffered},
+
+		flush:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go i.run()
+
+	return i, nil
+}
+
+func influxWriteURL(config InfluxDBConfig) (string, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return "", err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return "", fmt.Errorf("invalid InfluxDB URL: %s", config.URL)
+	}
+
+	query := url.Values{}
+	query.Set("precision", "ms")
+
+	if config.Bucket != "" {
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/api/v2/write"
+		query.Set("org", config.Org)
+		query.Set("bucket", config.Bucket)
+	} else if config.Database != "" {
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/write"
+		query.Set("db", config.Database)
+		if config.RetentionPolicy != "" {
+			query.Set("rp", config.RetentionPolicy)
+		}
+	} else {
+		return "", fmt.Errorf("either a database or a bucket is required for InfluxDB")
+	}
+
+	base.RawQuery = query.Encode()
+	return base.String(), nil
+}
+
+func (i *InfluxDB) OnStats(c *model.Container, s *model.Stats) {
+	i.add(containerLines(defaultPrefix, c, s, i.host.get())...)
+}
+
+func (i *InfluxDB) OnEngineStats(s *model.EngineStats) {
+	i.host.set(s.Host)
+	i.add(engineLines(defaultPrefix, s, time.Now())...)
+}
+
+func (i *InfluxDB) add(lines ...string) {
+	if i.buffer.add(lines...) >= i.config.BatchSize {
+		select {
+		case i.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (i *InfluxDB) run() {
+	defer close(i.stopped)
+
+	ticker := time.NewTicker(i.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			i.flushAll()
+		case <-i.flush:
+			i.flushAll()
+		case <-i.done:
+			return
+		}
+	}
+}
+
+func (i *InfluxDB) flushAll() {
+	for {
+		batch, dropped := i.buffer.take(i.config.BatchSize)
+		if len(batch) == 0 {
+			return
+		}
+
+		if dropped > 0 {
+			log.Println("Dropped", dropped, "lines while InfluxDB was unavailable")
+		}
+
+		retry, err := i.send(batch)
+		if err != nil {
+			log.Println("Failed to write to InfluxDB", err)
+
+			if retry {
+				i.buffer.requeue(batch)
+				return
+			}
+		}
+	}
+}
+
+// send writes a batch of lines and reports whether it should be retried on failure
+func (i *InfluxDB) send(batch []string) (bool, error) {
+	body := strings.Join(batch, "\n") + "\n"
+
+	request, err := http.NewRequest("POST", i.writeURL, strings.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	if i.config.Token != "" {
+		request.Header.Set("Authorization", "Token "+i.config.Token)
+	} else if i.config.Username != "" {
+		request.SetBasicAuth(i.config.Username, i.config.Password)
+	}
+
+	response, err := i.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, response.Body)
+
+		if logging.IsVerboseEnabled() {
+			log.Println("Written", len(batch), "lines to InfluxDB")
+		}
+
+		return false, nil
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	err = fmt.Errorf("unexpected response: %s %s", response.Status, bytes.TrimSpace(message))
+
+	// client errors mean the data is rejected, there is no point retrying those
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+func (i *InfluxDB) Close() error {
+	close(i.done)
+	<-i.stopped
+
+	i.flushAll()
+	return nil
+}
+
+func containerLines(prefix string, c *model.Container, s *model.Stats, host string) []string {
+	tags := formatInfluxTags(containerTags(c, host))
+	timestamp := formatInfluxTimestamp(sampleTime(s))
+
+	var lines []string
+	var fields []string
+
+	for idx, field := range containerFields {
+		fields = append(fields, escapeInfluxKey(field.Name)+"="+formatInfluxValue(field.Value(s)))
+
+		if idx == len(containerFields)-1 || containerFields[idx+1].Group != field.Group {
+			measurement := escapeInfluxMeasurement(prefix + "_" + field.Group)
+			lines = append(lines, measurement+tags+" "+strings.Join(fields, ",")+" "+timestamp)
+			fields = nil
+		}
+	}
+
+	if s.Rates != nil {
+		for _, field := range rateFields {
+			fields = append(fields, escapeInfluxKey(field.Name)+"="+formatInfluxValue(field.Value(s.Rates)))
+		}
+
+		measurement := escapeInfluxMeasurement(prefix + "_rate")
+		lines = append(lines, measurement+tags+" "+strings.Join(fields, ",")+" "+timestamp)
+	}
+
+	return lines
+}
+
+func engineLines(prefix string, s *model.EngineStats, timestamp time.Time) []string {
+	tags := formatInfluxTags(map[string]string{"engine_host": s.Host})
+
+	fields := make([]string, len(engineFields))
+	for idx, field := range engineFields {
+		fields[idx] = escapeInfluxKey(field.Name) + "=" + formatInfluxValue(field.Value(s))
+	}
+
+	measurement := escapeInfluxMeasurement(prefix + "_engine")
+	return []string{measurement + tags + " " + strings.Join(fields, ",") + " " + formatInfluxTimestamp(timestamp)}
+}
+
+func formatInfluxTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		// empty tag values are not valid in the line protocol
+		if key != "" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for _, key := range keys {
+		buf.WriteString(",")
+		buf.WriteString(escapeInfluxKey(key))
+		buf.WriteString("=")
+		buf.WriteString(escapeInfluxKey(tags[key]))
+	}
+
+	return buf.String()
+}
+
+func formatInfluxValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatInfluxTimestamp(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	influxKeyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+func escapeInfluxMeasurement(value string) string {
+	return influxMeasurementEscaper.Replace(value)
+}
+
+func escapeInfluxKey(value string) string {
+	return influxKeyEscaper.Replace(value)
+}
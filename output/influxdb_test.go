@@ -0,0 +1,110 @@
+package output
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestInfluxDBWrite(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []*http.Request
+		bodies   []string
+		failing  = true
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if failing {
+			failing = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	influxDB, err := NewInfluxDB(InfluxDBConfig{
+		URL:      server.URL,
+		Org:      "home",
+		Bucket:   "containers",
+		Token:    "secret",
+		Interval: time.Hour,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	influxDB.OnEngineStats(&model.EngineStats{Host: "pi-01", Images: 3})
+	influxDB.OnStats(&model.Container{
+		Name:   "web",
+		Image:  "nginx",
+		Labels: map[string]string{"com.docker.compose.service": "web server"},
+	}, &model.Stats{
+		Read:     time.Unix(1500000000, 0),
+		CpuStats: model.CpuStats{Percent: 12.5},
+	})
+
+	// the first attempt fails, the lines should be kept for the next one
+	influxDB.flushAll()
+	influxDB.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(requests) != 1 {
+		t.Fatal("Unexpected number of requests:", len(requests))
+	}
+
+	if requests[0].URL.Path != "/api/v2/write" || requests[0].URL.Query().Get("bucket") != "containers" {
+		t.Error("Unexpected URL:", requests[0].URL)
+	}
+	if requests[0].Header.Get("Authorization") != "Token secret" {
+		t.Error("Unexpected authorization:", requests[0].Header.Get("Authorization"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	if len(lines) != 5 {
+		t.Fatal("Unexpected number of lines:", len(lines))
+	}
+
+	if !strings.HasPrefix(lines[0], "cntm_engine,engine_host=pi-01 num_images=3,") {
+		t.Error("Unexpected engine line:", lines[0])
+	}
+
+	expected := "cntm_cpu,com.docker.compose.service=web\\ server,container_image=nginx,container_name=web,engine_host=pi-01 " +
+		"usage_total_seconds=0,usage_system_seconds=0,usage_user_seconds=0,usage_percent=12.5 1500000000000"
+	if lines[1] != expected {
+		t.Error("Unexpected CPU line:", lines[1])
+	}
+}
+
+func TestInfluxDBRateLines(t *testing.T) {
+	lines := containerLines("cntm", &model.Container{Name: "web"}, &model.Stats{
+		Read:  time.Unix(1500000000, 0),
+		Rates: &model.Rates{RxBytes: 1024, CpuCores: 0.25},
+	}, "pi-01")
+
+	last := lines[len(lines)-1]
+
+	if !strings.HasPrefix(last, "cntm_rate,") ||
+		!strings.Contains(last, "cpu_usage_cores=0.25") ||
+		!strings.Contains(last, "net_rx_bytes_per_second=1024") {
+		t.Error("Unexpected rate line:", last)
+	}
+}
@@ -0,0 +1,536 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	otlpScopeName = "github.com/rycus86/container-metrics"
+
+	otlpCumulative = 2
+)
+
+type OTLPConfig struct {
+	Endpoint string
+	Encoding string
+	Headers  map[string]string
+
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// OTLP exports the latest stats of each container on every interval
+// to an OpenTelemetry collector using OTLP/HTTP, with protobuf or JSON encoding
+type OTLP struct {
+	config  OTLPConfig
+	metrics string
+	client  *http.Client
+	host    engineHost
+
+	lock    sync.Mutex
+	pending map[string]otlpSample
+	series  map[string]*otlpSeries
+	engine  *model.EngineStats
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type otlpSample struct {
+	container model.Container
+	stats     *model.Stats
+	start     time.Time
+}
+
+// otlpSeries tracks the start time of the cumulative values of a container,
+// which is the time it was first seen, or the time of the last sample before the counters reset
+type otlpSeries struct {
+	start  time.Time
+	last   *model.Stats
+	seenAt time.Time
+}
+
+// the start times of the containers without samples for this long are forgotten
+const otlpSeriesExpiry = time.Hour
+
+func NewOTLP(config OTLPConfig) (*OTLP, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %s", config.Endpoint)
+	}
+
+	if config.Encoding == "" {
+		config.Encoding = "protobuf"
+	}
+	if config.Encoding != "protobuf" && config.Encoding != "json" {
+		return nil, fmt.Errorf("invalid OTLP encoding: %s", config.Encoding)
+	}
+
+	if !strings.HasSuffix(endpoint.Path, "/v1/metrics") {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/v1/metrics"
+	}
+
+	o := &OTLP{
+		config:  config,
+		metrics: endpoint.String(),
+		client:  &http.Client{Timeout: config.Timeout},
+		pending: map[string]otlpSample{},
+		series:  map[string]*otlpSeries{},
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go o.run()
+
+	return o, nil
+}
+
+func (o *OTLP) OnStats(c *model.Container, s *model.Stats) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	series, exists := o.series[c.Id]
+	if !exists {
+		series = &otlpSeries{start: sampleTime(s)}
+		o.series[c.Id] = series
+	} else if countersReset(series.last, s) {
+		// the container restarted since the last sample
+		series.start = sampleTime(series.last)
+	}
+
+	series.last = s
+	series.seenAt = time.Now()
+
+	// cumulative values make it safe to only keep the latest sample
+	o.pending[c.Id] = otlpSample{container: *c, stats: s, start: series.start}
+}
+
+// countersReset returns true if any of the cumulative values decreased
+func countersReset(previous, current *model.Stats) bool {
+	return current.CpuStats.User < previous.CpuStats.User ||
+		current.CpuStats.System < previous.CpuStats.System ||
+		current.IOStats.Read < previous.IOStats.Read ||
+		current.IOStats.Written < previous.IOStats.Written ||
+		current.NetworkStats.RxBytes < previous.NetworkStats.RxBytes ||
+		current.NetworkStats.RxPackets < previous.NetworkStats.RxPackets ||
+		current.NetworkStats.TxBytes < previous.NetworkStats.TxBytes ||
+		current.NetworkStats.TxPackets < previous.NetworkStats.TxPackets
+}
+
+func (o *OTLP) OnEngineStats(s *model.EngineStats) {
+	o.host.set(s.Host)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.engine = s
+}
+
+func (o *OTLP) run() {
+	defer close(o.stopped)
+
+	ticker := time.NewTicker(o.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.export()
+		case <-o.done:
+			return
+		}
+	}
+}
+
+func (o *OTLP) takePending() (map[string]otlpSample, *model.EngineStats) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	pending, engine := o.pending, o.engine
+
+	o.pending = map[string]otlpSample{}
+	o.engine = nil
+
+	for id, series := range o.series {
+		if time.Since(series.seenAt) > otlpSeriesExpiry {
+			delete(o.series, id)
+		}
+	}
+
+	return pending, engine
+}
+
+// requeue keeps the samples of a failed export, unless there are newer ones already
+func (o *OTLP) requeue(pending map[string]otlpSample, engine *model.EngineStats) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for id, sample := range pending {
+		if _, exists := o.pending[id]; !exists {
+			o.pending[id] = sample
+		}
+	}
+
+	if o.engine == nil {
+		o.engine = engine
+	}
+}
+
+func (o *OTLP) export() {
+	pending, engine := o.takePending()
+	if len(pending) == 0 && engine == nil {
+		return
+	}
+
+	request := buildOTLPRequest(pending, engine, o.host.get(), time.Now())
+
+	retry, err := o.send(request)
+	if err != nil {
+		log.Println("Failed to export metrics with OTLP", err)
+
+		if retry {
+			o.requeue(pending, engine)
+		}
+
+		return
+	}
+
+	if logging.IsVerboseEnabled() {
+		log.Println("Exported metrics of", len(pending), "containers with OTLP")
+	}
+}
+
+func (o *OTLP) send(request *otlpRequest) (bool, error) {
+	var (
+		body        []byte
+		contentType string
+	)
+
+	if o.config.Encoding == "json" {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return false, err
+		}
+
+		body, contentType = encoded, "application/json"
+	} else {
+		w := newProtoWriter()
+		request.marshal(w)
+
+		body, contentType = w.bytes(), "application/x-protobuf"
+	}
+
+	httpRequest, err := http.NewRequest("POST", o.metrics, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	httpRequest.Header.Set("Content-Type", contentType)
+	for name, value := range o.config.Headers {
+		httpRequest.Header.Set(name, value)
+	}
+
+	response, err := o.client.Do(httpRequest)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, fmt.Errorf("unexpected response: %s", response.Status)
+	default:
+		return false, fmt.Errorf("unexpected response: %s", response.Status)
+	}
+}
+
+func (o *OTLP) Close() error {
+	close(o.done)
+	<-o.stopped
+
+	o.export()
+	return nil
+}
+
+func buildOTLPRequest(pending map[string]otlpSample, engine *model.EngineStats, host string, now time.Time) *otlpRequest {
+	request := &otlpRequest{}
+
+	for _, sample := range pending {
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource: otlpResource{Attributes: containerResource(&sample.container, host)},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: containerOTLPMetrics(sample.stats, sample.start),
+			}},
+		})
+	}
+
+	if engine != nil {
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource: otlpResource{Attributes: []otlpKeyValue{
+				otlpAttribute("host.name", engine.Host),
+			}},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: engineOTLPMetrics(engine, now),
+			}},
+		})
+	}
+
+	return request
+}
+
+func containerResource(c *model.Container, host string) []otlpKeyValue {
+	attributes := []otlpKeyValue{
+		otlpAttribute("container.id", c.Id),
+		otlpAttribute("container.name", c.Name),
+		otlpAttribute("container.image.name", c.Image),
+		otlpAttribute("container.runtime", "docker"),
+	}
+
+	if host != "" {
+		attributes = append(attributes, otlpAttribute("host.name", host))
+	}
+
+	for name, value := range c.Labels {
+		attributes = append(attributes, otlpAttribute("container.label."+name, value))
+	}
+
+	return attributes
+}
+
+func containerOTLPMetrics(s *model.Stats, start time.Time) []otlpMetric {
+	timestamp := uint64(sampleTime(s).UnixNano())
+	startTimestamp := uint64(start.UnixNano())
+
+	point := func(value float64, attributes ...otlpKeyValue) otlpDataPoint {
+		return otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsDouble: value}
+	}
+
+	// the cumulative values start when the container was first seen, or restarted
+	counterPoint := func(value float64, attributes ...otlpKeyValue) otlpDataPoint {
+		p := point(value, attributes...)
+		p.StartTimeUnixNano = startTimestamp
+		return p
+	}
+
+	return []otlpMetric{
+		otlpCounter("container.cpu.time", "Total CPU time consumed", "s",
+			counterPoint(float64(s.CpuStats.User)/float64(time.Second), otlpAttribute("cpu.mode", "user")),
+			counterPoint(float64(s.CpuStats.System)/float64(time.Second), otlpAttribute("cpu.mode", "system"))),
+		otlpGauge("container.cpu.usage", "Container's CPU usage, measured in cpus", "{cpu}",
+			point(s.CpuStats.Percent/100.0)),
+		otlpGauge("container.memory.usage", "Memory usage of the container", "By",
+			point(s.MemoryStats.Usage)),
+		otlpGauge("container.memory.limit", "Memory available to the container", "By",
+			point(float64(s.MemoryStats.Total))),
+		otlpCounter("container.disk.io", "Disk bytes for the container", "By",
+			counterPoint(float64(s.IOStats.Read), otlpAttribute("disk.io.direction", "read")),
+			counterPoint(float64(s.IOStats.Written), otlpAttribute("disk.io.direction", "write"))),
+		otlpCounter("container.network.io", "Network bytes for the container", "By",
+			counterPoint(float64(s.NetworkStats.RxBytes), otlpAttribute("network.io.direction", "receive")),
+			counterPoint(float64(s.NetworkStats.TxBytes), otlpAttribute("network.io.direction", "transmit"))),
+		otlpCounter("container.network.packets", "Network packets for the container", "{packet}",
+			counterPoint(float64(s.NetworkStats.RxPackets), otlpAttribute("network.io.direction", "receive")),
+			counterPoint(float64(s.NetworkStats.TxPackets), otlpAttribute("network.io.direction", "transmit"))),
+		otlpCounter("container.network.dropped", "Network packets dropped for the container", "{packet}",
+			counterPoint(float64(s.NetworkStats.RxDropped), otlpAttribute("network.io.direction", "receive")),
+			counterPoint(float64(s.NetworkStats.TxDropped), otlpAttribute("network.io.direction", "transmit"))),
+		otlpCounter("container.network.errors", "Network errors for the container", "{error}",
+			counterPoint(float64(s.NetworkStats.RxErrors), otlpAttribute("network.io.direction", "receive")),
+			counterPoint(float64(s.NetworkStats.TxErrors), otlpAttribute("network.io.direction", "transmit"))),
+	}
+}
+
+func engineOTLPMetrics(s *model.EngineStats, now time.Time) []otlpMetric {
+	timestamp := uint64(now.UnixNano())
+
+	point := func(value int, attributes ...otlpKeyValue) otlpDataPoint {
+		return otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsDouble: float64(value)}
+	}
+
+	return []otlpMetric{
+		otlpGauge("container.engine.images", "Number of images", "{image}",
+			point(s.Images)),
+		otlpGauge("container.engine.containers", "Number of containers", "{container}",
+			point(s.ContainersRunning, otlpAttribute("container.state", "running")),
+			point(s.ContainersPaused, otlpAttribute("container.state", "paused")),
+			point(s.ContainersStopped, otlpAttribute("container.state", "stopped"))),
+	}
+}
+
+func otlpAttribute(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+func otlpGauge(name, description, unit string, points ...otlpDataPoint) otlpMetric {
+	return otlpMetric{
+		Name: name, Description: description, Unit: unit,
+		Gauge: &otlpGaugeData{DataPoints: points},
+	}
+}
+
+func otlpCounter(name, description, unit string, points ...otlpDataPoint) otlpMetric {
+	return otlpMetric{
+		Name: name, Description: description, Unit: unit,
+		Sum: &otlpSumData{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true},
+	}
+}
+
+// The types below follow the OTLP ExportMetricsServiceRequest message,
+// with the field names of the JSON encoding and the field numbers of the protobuf one
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func (m *otlpRequest) marshal(w *protoWriter) {
+	for idx := range m.ResourceMetrics {
+		w.message(1, m.ResourceMetrics[idx].marshal)
+	}
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+func (m *otlpResourceMetrics) marshal(w *protoWriter) {
+	w.message(1, m.Resource.marshal)
+	for idx := range m.ScopeMetrics {
+		w.message(2, m.ScopeMetrics[idx].marshal)
+	}
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+func (m *otlpResource) marshal(w *protoWriter) {
+	for idx := range m.Attributes {
+		w.message(1, m.Attributes[idx].marshal)
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+func (m *otlpKeyValue) marshal(w *protoWriter) {
+	w.stringField(1, m.Key)
+	w.message(2, m.Value.marshal)
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (m *otlpAnyValue) marshal(w *protoWriter) {
+	w.stringField(1, m.StringValue)
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+func (m *otlpScopeMetrics) marshal(w *protoWriter) {
+	w.message(1, m.Scope.marshal)
+	for idx := range m.Metrics {
+		w.message(2, m.Metrics[idx].marshal)
+	}
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+func (m *otlpScope) marshal(w *protoWriter) {
+	w.stringField(1, m.Name)
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Gauge       *otlpGaugeData `json:"gauge,omitempty"`
+	Sum         *otlpSumData   `json:"sum,omitempty"`
+}
+
+func (m *otlpMetric) marshal(w *protoWriter) {
+	w.stringField(1, m.Name)
+	w.stringField(2, m.Description)
+	w.stringField(3, m.Unit)
+
+	if m.Gauge != nil {
+		w.message(5, m.Gauge.marshal)
+	}
+	if m.Sum != nil {
+		w.message(7, m.Sum.marshal)
+	}
+}
+
+type otlpGaugeData struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+func (m *otlpGaugeData) marshal(w *protoWriter) {
+	for idx := range m.DataPoints {
+		w.message(1, m.DataPoints[idx].marshal)
+	}
+}
+
+type otlpSumData struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+func (m *otlpSumData) marshal(w *protoWriter) {
+	for idx := range m.DataPoints {
+		w.message(1, m.DataPoints[idx].marshal)
+	}
+
+	w.varintField(2, uint64(m.AggregationTemporality))
+	w.boolField(3, m.IsMonotonic)
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64         `json:"timeUnixNano,string"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+func (m *otlpDataPoint) marshal(w *protoWriter) {
+	w.fixed64Field(2, m.StartTimeUnixNano)
+	w.fixed64Field(3, m.TimeUnixNano)
+	w.doubleField(4, m.AsDouble)
+	for idx := range m.Attributes {
+		w.message(7, m.Attributes[idx].marshal)
+	}
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestOTLPExport(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	otlp, err := NewOTLP(OTLPConfig{
+		Endpoint: server.URL,
+		Encoding: "json",
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+		Interval: time.Hour,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	otlp.OnEngineStats(&model.EngineStats{Host: "pi-01"})
+	otlp.OnStats(&model.Container{Id: "abcd", Name: "web", Image: "nginx"}, &model.Stats{
+		Read:         time.Unix(1500000000, 0),
+		NetworkStats: model.NetworkStats{RxBytes: 1024},
+	})
+
+	otlp.Close()
+
+	request := <-received
+	if request.URL.Path != "/v1/metrics" {
+		t.Error("Unexpected path:", request.URL.Path)
+	}
+	if request.Header.Get("Authorization") != "Bearer secret" {
+		t.Error("Unexpected authorization:", request.Header.Get("Authorization"))
+	}
+
+	var decoded otlpRequest
+	if err := json.Unmarshal(<-bodies, &decoded); err != nil {
+		t.Fatal("Failed to decode the request:", err)
+	}
+
+	if len(decoded.ResourceMetrics) != 2 {
+		t.Fatal("Unexpected resources:", decoded.ResourceMetrics)
+	}
+
+	resource := decoded.ResourceMetrics[0]
+
+	attributes := map[string]string{}
+	for _, attribute := range resource.Resource.Attributes {
+		attributes[attribute.Key] = attribute.Value.StringValue
+	}
+
+	if attributes["container.id"] != "abcd" || attributes["container.image.name"] != "nginx" || attributes["host.name"] != "pi-01" {
+		t.Error("Unexpected resource attributes:", attributes)
+	}
+
+	for _, metric := range resource.ScopeMetrics[0].Metrics {
+		if metric.Name != "container.network.io" {
+			continue
+		}
+
+		point := metric.Sum.DataPoints[0]
+		if point.AsDouble != 1024 || point.TimeUnixNano != 1500000000000000000 || point.Attributes[0].Value.StringValue != "receive" {
+			t.Error("Unexpected data point:", point)
+		}
+
+		return
+	}
+
+	t.Error("Network metric not found")
+}
+
+func TestOTLPProtobuf(t *testing.T) {
+	w := newProtoWriter()
+
+	metric := otlpGauge("m", "", "", otlpDataPoint{TimeUnixNano: 1, AsDouble: 0})
+	metric.marshal(w)
+
+	// name: tag 0x0a, length 1, "m"
+	// gauge: tag 0x2a, length 20, data point: tag 0x0a, length 18,
+	//   time: tag 0x19 + 8 bytes, value: tag 0x21 + 8 bytes
+	expected := []byte{
+		0x0a, 0x01, 'm',
+		0x2a, 0x14, 0x0a, 0x12,
+		0x19, 0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x21, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+
+	if string(w.bytes()) != string(expected) {
+		t.Errorf("Unexpected encoding: %x", w.bytes())
+	}
+}
+
+func TestOTLPStartTime(t *testing.T) {
+	otlp, err := NewOTLP(OTLPConfig{Endpoint: "http://localhost:4318", Interval: time.Hour})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+	defer close(otlp.done)
+
+	c := &model.Container{Id: "abcd", Name: "web"}
+	first := time.Unix(1500000000, 0)
+
+	sample := func(read time.Time, rx uint64) time.Time {
+		otlp.OnStats(c, &model.Stats{Read: read, NetworkStats: model.NetworkStats{RxBytes: rx}})
+		pending, _ := otlp.takePending()
+		return pending["abcd"].start
+	}
+
+	if start := sample(first, 1024); !start.Equal(first) {
+		t.Error("Unexpected start time for the first sample:", start)
+	}
+
+	if start := sample(first.Add(time.Minute), 2048); !start.Equal(first) {
+		t.Error("Unexpected start time after an increase:", start)
+	}
+
+	// the counters reset when the container restarts
+	if start := sample(first.Add(2*time.Minute), 100); !start.Equal(first.Add(time.Minute)) {
+		t.Error("Unexpected start time after a reset:", start)
+	}
+
+	metrics := containerOTLPMetrics(&model.Stats{Read: first.Add(2 * time.Minute)}, first.Add(time.Minute))
+
+	for _, metric := range metrics {
+		if metric.Sum != nil && metric.Sum.DataPoints[0].StartTimeUnixNano != uint64(first.Add(time.Minute).UnixNano()) {
+			t.Error("Unexpected start time on", metric.Name)
+		}
+		if metric.Gauge != nil && metric.Gauge.DataPoints[0].StartTimeUnixNano != 0 {
+			t.Error("Unexpected start time on the gauge", metric.Name)
+		}
+	}
+}
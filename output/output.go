@@ -0,0 +1,185 @@
+package output
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+const defaultPrefix = "cntm"
+
+// Output pushes the collected stats to an external system
+type Output interface {
+	OnStats(*model.Container, *model.Stats)
+	OnEngineStats(*model.EngineStats)
+
+	Close() error
+}
+
+type statsField struct {
+	Group   string
+	Name    string
+	Counter bool
+	Value   func(*model.Stats) float64
+}
+
+type rateField struct {
+	Group string
+	Name  string
+	Value func(*model.Rates) float64
+}
+
+type engineField struct {
+	Group string
+	Name  string
+	Value func(*model.EngineStats) float64
+}
+
+var containerFields = []statsField{
+	// CPU fields
+	{"cpu", "usage_total_seconds", true, func(s *model.Stats) float64 {
+		return float64(s.CpuStats.Total) / float64(time.Second)
+	}},
+	{"cpu", "usage_system_seconds", true, func(s *model.Stats) float64 {
+		return float64(s.CpuStats.System) / float64(time.Second)
+	}},
+	{"cpu", "usage_user_seconds", true, func(s *model.Stats) float64 {
+		return float64(s.CpuStats.User) / float64(time.Second)
+	}},
+	{"cpu", "usage_percent", false, func(s *model.Stats) float64 {
+		return s.CpuStats.Percent
+	}},
+
+	// Memory fields
+	{"memory", "total_bytes", false, func(s *model.Stats) float64 {
+		return float64(s.MemoryStats.Total)
+	}},
+	{"memory", "usage_bytes", false, func(s *model.Stats) float64 {
+		return s.MemoryStats.Usage
+	}},
+	{"memory", "usage_percent", false, func(s *model.Stats) float64 {
+		return s.MemoryStats.Percent
+	}},
+
+	// I/O fields
+	{"io", "read_bytes", true, func(s *model.Stats) float64 {
+		return float64(s.IOStats.Read)
+	}},
+	{"io", "write_bytes", true, func(s *model.Stats) float64 {
+		return float64(s.IOStats.Written)
+	}},
+
+	// Network fields
+	{"net", "rx_bytes", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.RxBytes)
+	}},
+	{"net", "rx_packets", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.RxPackets)
+	}},
+	{"net", "rx_dropped", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.RxDropped)
+	}},
+	{"net", "rx_errors", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.RxErrors)
+	}},
+	{"net", "tx_bytes", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.TxBytes)
+	}},
+	{"net", "tx_packets", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.TxPackets)
+	}},
+	{"net", "tx_dropped", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.TxDropped)
+	}},
+	{"net", "tx_errors", true, func(s *model.Stats) float64 {
+		return float64(s.NetworkStats.TxErrors)
+	}},
+}
+
+// rateFields are the per-second rates of the cumulative values,
+// for the systems that cannot calculate them on their own
+var rateFields = []rateField{
+	{"rate", "cpu_usage_cores", func(r *model.Rates) float64 {
+		return r.CpuCores
+	}},
+	{"rate", "io_read_bytes_per_second", func(r *model.Rates) float64 {
+		return r.IORead
+	}},
+	{"rate", "io_write_bytes_per_second", func(r *model.Rates) float64 {
+		return r.IOWritten
+	}},
+	{"rate", "net_rx_bytes_per_second", func(r *model.Rates) float64 {
+		return r.RxBytes
+	}},
+	{"rate", "net_rx_packets_per_second", func(r *model.Rates) float64 {
+		return r.RxPackets
+	}},
+	{"rate", "net_tx_bytes_per_second", func(r *model.Rates) float64 {
+		return r.TxBytes
+	}},
+	{"rate", "net_tx_packets_per_second", func(r *model.Rates) float64 {
+		return r.TxPackets
+	}},
+}
+
+var engineFields = []engineField{
+	{"engine", "num_images", func(s *model.EngineStats) float64 {
+		return float64(s.Images)
+	}},
+	{"engine", "num_containers", func(s *model.EngineStats) float64 {
+		return float64(s.Containers)
+	}},
+	{"engine", "num_containers_running", func(s *model.EngineStats) float64 {
+		return float64(s.ContainersRunning)
+	}},
+	{"engine", "num_containers_stopped", func(s *model.EngineStats) float64 {
+		return float64(s.ContainersStopped)
+	}},
+	{"engine", "num_containers_paused", func(s *model.EngineStats) float64 {
+		return float64(s.ContainersPaused)
+	}},
+}
+
+// engineHost keeps track of the name of the engine
+// the container stats are coming from
+type engineHost struct {
+	lock sync.Mutex
+	host string
+}
+
+func (e *engineHost) set(host string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.host = host
+}
+
+func (e *engineHost) get() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.host
+}
+
+func containerTags(c *model.Container, host string) map[string]string {
+	tags := map[string]string{}
+
+	for name, value := range c.Labels {
+		tags[name] = value
+	}
+
+	tags["container_name"] = c.Name
+	tags["container_image"] = c.Image
+	tags["engine_host"] = host
+
+	return tags
+}
+
+func sampleTime(s *model.Stats) time.Time {
+	if s.Read.IsZero() {
+		return time.Now()
+	}
+
+	return s.Read
+}
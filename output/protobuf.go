@@ -0,0 +1,83 @@
+package output
+
+import (
+	"math"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoWriter encodes protobuf messages field by field,
+// for the wire formats we don't have generated code for
+type protoWriter struct {
+	buf *proto.Buffer
+}
+
+func newProtoWriter() *protoWriter {
+	return &protoWriter{buf: proto.NewBuffer(nil)}
+}
+
+func (w *protoWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.buf.EncodeVarint(uint64(field<<3 | wireType))
+}
+
+func (w *protoWriter) stringField(field int, value string) {
+	if value == "" {
+		return
+	}
+
+	w.tag(field, wireBytes)
+	w.buf.EncodeStringBytes(value)
+}
+
+func (w *protoWriter) varintField(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+
+	w.tag(field, wireVarint)
+	w.buf.EncodeVarint(value)
+}
+
+func (w *protoWriter) int64Field(field int, value int64) {
+	w.varintField(field, uint64(value))
+}
+
+func (w *protoWriter) boolField(field int, value bool) {
+	if value {
+		w.varintField(field, 1)
+	}
+}
+
+func (w *protoWriter) fixed64Field(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+
+	w.tag(field, wireFixed64)
+	w.buf.EncodeFixed64(value)
+}
+
+func (w *protoWriter) doubleField(field int, value float64) {
+	// always written, so that zero values are distinguishable in oneof fields
+	w.tag(field, wireFixed64)
+	w.buf.EncodeFixed64(math.Float64bits(value))
+}
+
+// message writes an embedded message encoded by the given function
+func (w *protoWriter) message(field int, encode func(*protoWriter)) {
+	embedded := newProtoWriter()
+	encode(embedded)
+
+	w.tag(field, wireBytes)
+	w.buf.EncodeRawBytes(embedded.bytes())
+}
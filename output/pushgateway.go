@@ -0,0 +1,199 @@
+package output
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+type PushgatewayConfig struct {
+	URL          string
+	Job          string
+	DeleteOnExit bool
+
+	Interval time.Duration
+	Timeout  time.Duration
+
+	Gatherer prometheus.Gatherer
+}
+
+// Pushgateway pushes the gathered metrics to a Prometheus Pushgateway
+// on every interval and on shutdown, grouped by the engine host
+type Pushgateway struct {
+	config PushgatewayConfig
+	client *http.Client
+	host   engineHost
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewPushgateway(config PushgatewayConfig) (*Pushgateway, error) {
+	if parsed, err := url.Parse(config.URL); err != nil {
+		return nil, err
+	} else if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Pushgateway URL: %s", config.URL)
+	}
+
+	if config.Job == "" {
+		return nil, fmt.Errorf("missing job name for the Pushgateway")
+	}
+	if config.Gatherer == nil {
+		config.Gatherer = prometheus.DefaultGatherer
+	}
+
+	p := &Pushgateway{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+func (p *Pushgateway) OnStats(c *model.Container, s *model.Stats) {
+	// the metrics are gathered from the registry on every interval
+}
+
+func (p *Pushgateway) OnEngineStats(s *model.EngineStats) {
+	p.host.set(s.Host)
+}
+
+func (p *Pushgateway) run() {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.push(); err != nil {
+				log.Println("Failed to push metrics to the Pushgateway", err)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// groupURL returns the URL of the metrics group of the engine host
+func (p *Pushgateway) groupURL() (string, bool) {
+	host := p.host.get()
+	if host == "" {
+		return "", false
+	}
+
+	return strings.TrimSuffix(p.config.URL, "/") +
+		"/metrics/job" + encodeGroupingSuffix(p.config.Job) +
+		"/engine_host" + encodeGroupingSuffix(host), true
+}
+
+func (p *Pushgateway) push() error {
+	target, ok := p.groupURL()
+	if !ok {
+		if logging.IsDebugEnabled() {
+			log.Println("Engine host is not known yet, skipping the push")
+		}
+
+		return nil
+	}
+
+	mfs, err := p.config.Gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := expfmt.NewEncoder(buf, expfmt.FmtText)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			// the Pushgateway rejects samples with timestamps
+			m.TimestampMs = nil
+		}
+
+		if err := encoder.Encode(mf); err != nil {
+			return err
+		}
+	}
+
+	if err := p.request("PUT", target, buf, string(expfmt.FmtText)); err != nil {
+		return err
+	}
+
+	if logging.IsVerboseEnabled() {
+		log.Println("Pushed", len(mfs), "metric families to the Pushgateway")
+	}
+
+	return nil
+}
+
+func (p *Pushgateway) request(method, target string, body io.Reader, contentType string) error {
+	request, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("unexpected response: %s %s", response.Status, bytes.TrimSpace(message))
+	}
+
+	io.Copy(ioutil.Discard, response.Body)
+	return nil
+}
+
+// Close pushes the metrics for the last time,
+// or deletes them from the Pushgateway if configured so
+func (p *Pushgateway) Close() error {
+	close(p.done)
+	<-p.stopped
+
+	if !p.config.DeleteOnExit {
+		return p.push()
+	}
+
+	if target, ok := p.groupURL(); ok {
+		return p.request("DELETE", target, nil, "")
+	}
+
+	return nil
+}
+
+// encodeGroupingSuffix encodes the label value as a path segment, using
+// the base64 form supported by the Pushgateway for values containing slashes
+func encodeGroupingSuffix(value string) string {
+	if strings.Contains(value, "/") {
+		return "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+
+	return "/" + url.PathEscape(value)
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestPushgateway(t *testing.T) {
+	type call struct {
+		method, path, body string
+	}
+
+	calls := make(chan call, 4)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		calls <- call{r.Method, r.URL.EscapedPath(), string(body)}
+	}))
+	defer server.Close()
+
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{
+			Name: proto.String("cntm_cpu_usage_percent"),
+			Help: proto.String("Total CPU usage in percent"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{
+				Gauge:       &dto.Gauge{Value: proto.Float64(12.5)},
+				TimestampMs: proto.Int64(1500000000000),
+			}},
+		}}, nil
+	})
+
+	pushgateway, err := NewPushgateway(PushgatewayConfig{
+		URL:          server.URL,
+		Job:          "container-metrics",
+		DeleteOnExit: true,
+		Interval:     time.Hour,
+		Timeout:      time.Second,
+		Gatherer:     gatherer,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	pushgateway.OnEngineStats(&model.EngineStats{Host: "pi/01"})
+
+	if err := pushgateway.push(); err != nil {
+		t.Fatal("Failed to push:", err)
+	}
+	if err := pushgateway.Close(); err != nil {
+		t.Fatal("Failed to close:", err)
+	}
+
+	path := "/metrics/job/container-metrics/engine_host@base64/cGkvMDE"
+
+	pushed := <-calls
+	if pushed.method != "PUT" || pushed.path != path {
+		t.Error("Unexpected push:", pushed.method, pushed.path)
+	}
+	if !strings.Contains(pushed.body, "cntm_cpu_usage_percent 12.5\n") {
+		t.Error("Unexpected body:", pushed.body)
+	}
+
+	deleted := <-calls
+	if deleted.method != "DELETE" || deleted.path != path {
+		t.Error("Unexpected delete:", deleted.method, deleted.path)
+	}
+}
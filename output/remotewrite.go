@@ -0,0 +1,381 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	remoteWriteRetries      = 3
+	remoteWriteRetryBackoff = 500 * time.Millisecond
+)
+
+type RemoteWriteConfig struct {
+	URL            string
+	Username       string
+	Password       string
+	BearerToken    string
+	ExternalLabels map[string]string
+
+	MaxPending int
+	Interval   time.Duration
+	Timeout    time.Duration
+
+	Gatherer prometheus.Gatherer
+}
+
+// RemoteWrite pushes the gathered metrics to a Prometheus remote write endpoint
+// on every interval, keeping a bounded in-memory queue of the failed requests
+type RemoteWrite struct {
+	config RemoteWriteConfig
+	client *http.Client
+
+	lock    sync.Mutex
+	pending [][]byte
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type remoteWriteLabel struct {
+	name, value string
+}
+
+type remoteWriteSeries struct {
+	labels    []remoteWriteLabel
+	value     float64
+	timestamp int64
+}
+
+func NewRemoteWrite(config RemoteWriteConfig) (*RemoteWrite, error) {
+	if parsed, err := url.Parse(config.URL); err != nil {
+		return nil, err
+	} else if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid remote write URL: %s", config.URL)
+	}
+
+	if config.MaxPending <= 0 {
+		config.MaxPending = 1
+	}
+	if config.Gatherer == nil {
+		config.Gatherer = prometheus.DefaultGatherer
+	}
+
+	rw := &RemoteWrite{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go rw.run()
+
+	return rw, nil
+}
+
+func (rw *RemoteWrite) OnStats(c *model.Container, s *model.Stats) {
+	// the metrics are gathered from the registry on every interval
+}
+
+func (rw *RemoteWrite) OnEngineStats(s *model.EngineStats) {
+	// the metrics are gathered from the registry on every interval
+}
+
+func (rw *RemoteWrite) run() {
+	defer close(rw.stopped)
+
+	ticker := time.NewTicker(rw.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rw.push()
+		case <-rw.done:
+			return
+		}
+	}
+}
+
+func (rw *RemoteWrite) push() {
+	mfs, err := rw.config.Gatherer.Gather()
+	if err != nil {
+		log.Println("Failed to gather the metrics for remote write", err)
+	}
+
+	if len(mfs) > 0 {
+		series := convertMetricFamilies(mfs, rw.config.ExternalLabels, time.Now())
+		rw.enqueue(snappy.Encode(nil, encodeWriteRequest(series)))
+	}
+
+	rw.sendPending()
+}
+
+func (rw *RemoteWrite) enqueue(request []byte) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	rw.pending = append(rw.pending, request)
+
+	if overflow := len(rw.pending) - rw.config.MaxPending; overflow > 0 {
+		log.Println("Dropping", overflow, "remote write requests that failed to send")
+		rw.pending = rw.pending[overflow:]
+	}
+}
+
+func (rw *RemoteWrite) next() []byte {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	if len(rw.pending) == 0 {
+		return nil
+	}
+
+	return rw.pending[0]
+}
+
+func (rw *RemoteWrite) removeFirst() {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	if len(rw.pending) > 0 {
+		rw.pending = rw.pending[1:]
+	}
+}
+
+// sendPending sends the queued requests in order, and keeps the ones
+// that failed with a recoverable error for the next interval
+func (rw *RemoteWrite) sendPending() {
+	for {
+		request := rw.next()
+		if request == nil {
+			return
+		}
+
+		if err := rw.sendWithRetries(request); err != nil {
+			if recoverable, ok := err.(recoverableError); ok {
+				log.Println("Failed to send remote write request, will retry", recoverable.error)
+				return
+			}
+
+			log.Println("Failed to send remote write request", err)
+		} else if logging.IsVerboseEnabled() {
+			log.Println("Sent remote write request of", len(request), "bytes")
+		}
+
+		rw.removeFirst()
+	}
+}
+
+type recoverableError struct {
+	error
+}
+
+func (rw *RemoteWrite) sendWithRetries(request []byte) error {
+	backoff := remoteWriteRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := rw.send(request)
+		if _, ok := err.(recoverableError); !ok || attempt >= remoteWriteRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-rw.done:
+			return err
+		}
+	}
+}
+
+func (rw *RemoteWrite) send(request []byte) error {
+	httpRequest, err := http.NewRequest("POST", rw.config.URL, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Header.Set("Content-Encoding", "snappy")
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	httpRequest.Header.Set("User-Agent", "container-metrics")
+	httpRequest.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	if rw.config.BearerToken != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+rw.config.BearerToken)
+	} else if rw.config.Username != "" {
+		httpRequest.SetBasicAuth(rw.config.Username, rw.config.Password)
+	}
+
+	response, err := rw.client.Do(httpRequest)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	err = fmt.Errorf("unexpected response: %s %s", response.Status, bytes.TrimSpace(message))
+
+	if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+
+	return err
+}
+
+func (rw *RemoteWrite) Close() error {
+	close(rw.done)
+	<-rw.stopped
+
+	rw.sendPending()
+	return nil
+}
+
+func convertMetricFamilies(mfs []*dto.MetricFamily, externalLabels map[string]string, now time.Time) []remoteWriteSeries {
+	var series []remoteWriteSeries
+
+	defaultTimestamp := now.UnixNano() / int64(time.Millisecond)
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+
+		for _, m := range mf.GetMetric() {
+			timestamp := defaultTimestamp
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+
+			add := func(name string, value float64, extraName, extraValue string) {
+				labels := []remoteWriteLabel{{"__name__", name}}
+
+				for key, value := range externalLabels {
+					labels = append(labels, remoteWriteLabel{key, value})
+				}
+
+				for _, label := range m.GetLabel() {
+					labels = setLabel(labels, label.GetName(), label.GetValue())
+				}
+
+				if extraName != "" {
+					labels = setLabel(labels, extraName, extraValue)
+				}
+
+				labels = withoutEmptyLabels(labels)
+
+				sort.Slice(labels, func(i, j int) bool {
+					return labels[i].name < labels[j].name
+				})
+
+				series = append(series, remoteWriteSeries{labels: labels, value: value, timestamp: timestamp})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					add(name, q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", m.GetSummary().GetSampleSum(), "", "")
+				add(name+"_count", float64(m.GetSummary().GetSampleCount()), "", "")
+			case dto.MetricType_HISTOGRAM:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				add(name+"_bucket", float64(m.GetHistogram().GetSampleCount()), "le", "+Inf")
+				add(name+"_sum", m.GetHistogram().GetSampleSum(), "", "")
+				add(name+"_count", float64(m.GetHistogram().GetSampleCount()), "", "")
+			default:
+				add(name, m.GetUntyped().GetValue(), "", "")
+			}
+		}
+	}
+
+	return series
+}
+
+// setLabel replaces the value of an existing label, so that
+// the labels of the metric take precedence over the external ones
+func setLabel(labels []remoteWriteLabel, name, value string) []remoteWriteLabel {
+	for idx := range labels {
+		if labels[idx].name == name {
+			labels[idx].value = value
+			return labels
+		}
+	}
+
+	return append(labels, remoteWriteLabel{name, value})
+}
+
+// withoutEmptyLabels drops the labels with empty values, as these are
+// equivalent to missing labels in Prometheus
+func withoutEmptyLabels(labels []remoteWriteLabel) []remoteWriteLabel {
+	filtered := labels[:0]
+
+	for _, label := range labels {
+		if label.value != "" {
+			filtered = append(filtered, label)
+		}
+	}
+
+	return filtered
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes the series as a prometheus.WriteRequest protobuf message
+func encodeWriteRequest(series []remoteWriteSeries) []byte {
+	w := newProtoWriter()
+
+	for _, item := range series {
+		ts := item
+
+		w.message(1, func(w *protoWriter) {
+			for _, label := range ts.labels {
+				l := label
+
+				w.message(1, func(w *protoWriter) {
+					w.stringField(1, l.name)
+					w.stringField(2, l.value)
+				})
+			}
+
+			w.message(2, func(w *protoWriter) {
+				w.doubleField(1, ts.value)
+				w.int64Field(2, ts.timestamp)
+			})
+		})
+	}
+
+	return w.bytes()
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{
+			Name: proto.String("cntm_memory_usage_bytes"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{
+				Label: []*dto.LabelPair{
+					{Name: proto.String("container_name"), Value: proto.String("web")},
+					{Name: proto.String("empty"), Value: proto.String("")},
+				},
+				Gauge: &dto.Gauge{Value: proto.Float64(512)},
+			}},
+		}}, nil
+	})
+
+	remoteWrite, err := NewRemoteWrite(RemoteWriteConfig{
+		URL:            server.URL,
+		BearerToken:    "secret",
+		ExternalLabels: map[string]string{"site": "home"},
+		MaxPending:     5,
+		Interval:       time.Hour,
+		Timeout:        time.Second,
+		Gatherer:       gatherer,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+
+	remoteWrite.push()
+	remoteWrite.Close()
+
+	decoded, err := snappy.Decode(nil, <-bodies)
+	if err != nil {
+		t.Fatal("Failed to decompress the request:", err)
+	}
+
+	for _, expected := range []string{"__name__", "cntm_memory_usage_bytes", "container_name", "web", "site", "home"} {
+		if !bytes.Contains(decoded, []byte(expected)) {
+			t.Error("Expected string not found:", expected)
+		}
+	}
+
+	if bytes.Contains(decoded, []byte("empty")) {
+		t.Error("Unexpected empty label found")
+	}
+}
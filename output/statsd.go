@@ -0,0 +1,224 @@
+package output
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/metrics"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	defaultStatsDPacketSize = 1432
+	counterStateExpiry      = 10 * time.Minute
+)
+
+var dogStatsDTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+type StatsDConfig struct {
+	Address       string
+	Protocol      string
+	Prefix        string
+	Tags          bool
+	MaxPacketSize int
+}
+
+// StatsD emits every sample as StatsD gauges and counters, using the
+// DogStatsD tag extension for the container metadata when enabled
+type StatsD struct {
+	config StatsDConfig
+	host   engineHost
+
+	lock     sync.Mutex
+	conn     net.Conn
+	previous map[string]*counterState
+}
+
+type counterState struct {
+	values []float64
+	seen   time.Time
+}
+
+func NewStatsD(config StatsDConfig) (*StatsD, error) {
+	if config.Protocol == "" {
+		config.Protocol = "udp"
+	}
+	if config.Protocol != "udp" && config.Protocol != "unixgram" {
+		return nil, fmt.Errorf("invalid StatsD protocol: %s", config.Protocol)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("missing StatsD address")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = defaultPrefix
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = defaultStatsDPacketSize
+	}
+
+	return &StatsD{
+		config:   config,
+		previous: map[string]*counterState{},
+	}, nil
+}
+
+func (sd *StatsD) OnStats(c *model.Container, s *model.Stats) {
+	host := sd.host.get()
+
+	var (
+		tags   string
+		prefix = sd.config.Prefix + "."
+	)
+
+	if sd.config.Tags {
+		tags = formatDogStatsDTags(containerTags(c, host))
+	} else {
+		// without tags, the container has to be part of the name
+		prefix += metrics.SanitizeName(c.Name) + "."
+	}
+
+	deltas := sd.counterDeltas(c.Id, s)
+
+	var lines []string
+	for idx, field := range containerFields {
+		name := prefix + field.Group + "." + field.Name
+
+		if field.Counter {
+			if delta, ok := deltas[idx]; ok {
+				lines = append(lines, statsDLine(name, delta, "c", tags))
+			}
+		} else {
+			lines = append(lines, statsDLine(name, field.Value(s), "g", tags))
+		}
+	}
+
+	sd.send(lines)
+}
+
+func (sd *StatsD) OnEngineStats(s *model.EngineStats) {
+	sd.host.set(s.Host)
+
+	var (
+		tags   string
+		prefix = sd.config.Prefix + "."
+	)
+
+	if sd.config.Tags {
+		tags = formatDogStatsDTags(map[string]string{"engine_host": s.Host})
+	}
+
+	lines := make([]string, len(engineFields))
+	for idx, field := range engineFields {
+		lines[idx] = statsDLine(prefix+field.Group+"."+field.Name, field.Value(s), "g", tags)
+	}
+
+	sd.send(lines)
+	sd.expireCounters()
+}
+
+// counterDeltas calculates the increments of the cumulative values
+// since the previous sample of the same container
+func (sd *StatsD) counterDeltas(id string, s *model.Stats) map[int]float64 {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	values := make([]float64, len(containerFields))
+	for idx, field := range containerFields {
+		if field.Counter {
+			values[idx] = field.Value(s)
+		}
+	}
+
+	deltas := map[int]float64{}
+
+	if previous, ok := sd.previous[id]; ok {
+		for idx, field := range containerFields {
+			// skip the counters that were reset
+			if field.Counter && values[idx] >= previous.values[idx] {
+				deltas[idx] = values[idx] - previous.values[idx]
+			}
+		}
+	}
+
+	sd.previous[id] = &counterState{values: values, seen: time.Now()}
+
+	return deltas
+}
+
+func (sd *StatsD) expireCounters() {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	for id, state := range sd.previous {
+		if time.Since(state.seen) > counterStateExpiry {
+			delete(sd.previous, id)
+		}
+	}
+}
+
+func (sd *StatsD) send(lines []string) {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	if sd.conn == nil {
+		conn, err := net.Dial(sd.config.Protocol, sd.config.Address)
+		if err != nil {
+			log.Println("Failed to connect to StatsD", err)
+			return
+		}
+
+		sd.conn = conn
+	}
+
+	if _, err := writePackets(sd.conn, lines, sd.config.MaxPacketSize); err != nil {
+		log.Println("Failed to send metrics to StatsD", err)
+
+		// reconnect on the next sample
+		sd.conn.Close()
+		sd.conn = nil
+	}
+}
+
+func (sd *StatsD) Close() error {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	if sd.conn != nil {
+		return sd.conn.Close()
+	}
+
+	return nil
+}
+
+func statsDLine(name string, value float64, metricType, tags string) string {
+	return name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + metricType + tags + "\n"
+}
+
+func formatDogStatsDTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return ""
+	}
+
+	sort.Strings(keys)
+
+	formatted := make([]string, len(keys))
+	for idx, key := range keys {
+		formatted[idx] = dogStatsDTagEscaper.Replace(key) + ":" + dogStatsDTagEscaper.Replace(tags[key])
+	}
+
+	return "|#" + strings.Join(formatted, ",")
+}
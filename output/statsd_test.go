@@ -0,0 +1,79 @@
+package output
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestStatsDSend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer conn.Close()
+
+	statsD, err := NewStatsD(StatsDConfig{
+		Address:       conn.LocalAddr().String(),
+		Prefix:        "home",
+		Tags:          true,
+		MaxPacketSize: 512,
+	})
+	if err != nil {
+		t.Fatal("Failed to create the output:", err)
+	}
+	defer statsD.Close()
+
+	container := &model.Container{
+		Id: "abcd", Name: "web", Labels: map[string]string{"tier": "front,end"},
+	}
+
+	statsD.OnStats(container, &model.Stats{
+		NetworkStats: model.NetworkStats{RxBytes: 100},
+	})
+	statsD.OnStats(container, &model.Stats{
+		MemoryStats:  model.MemoryStats{Percent: 25},
+		NetworkStats: model.NetworkStats{RxBytes: 250},
+	})
+
+	var lines []string
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+
+		if n > 512 {
+			t.Error("Packet too large:", n)
+		}
+
+		lines = append(lines, strings.Split(strings.TrimSpace(string(buf[:n])), "\n")...)
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	}
+
+	tags := "|#container_name:web,tier:front_end"
+
+	expected := map[string]bool{
+		"home.memory.usage_percent:25|g" + tags: false,
+		"home.net.rx_bytes:150|c" + tags:        false,
+	}
+
+	for _, line := range lines {
+		if _, ok := expected[line]; ok {
+			expected[line] = true
+		}
+	}
+
+	for line, found := range expected {
+		if !found {
+			t.Error("Expected line not found:", line)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/model"
+)
+
+const (
+	segmentPrefix     = "cntm-"
+	segmentSuffix     = ".jsonl"
+	compactedSuffix   = ".jsonl.gz"
+	segmentDateLayout = "2006-01-02"
+
+	storeMaintenanceInterval = time.Hour
+)
+
+type StoreConfig struct {
+	Directory string
+	Retention time.Duration
+
+	Interval time.Duration
+}
+
+// Store records every sample on disk in append-only segment files per day,
+// compressing the segments of the previous days and deleting the ones
+// older than the retention time
+type Store struct {
+	config StoreConfig
+	host   engineHost
+
+	lock    sync.Mutex
+	day     string
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// storeRecord is a single line in a segment file,
+// with either container or engine stats
+type storeRecord struct {
+	Time time.Time `json:"time"`
+	Host string    `json:"host,omitempty"`
+
+	Container *model.Container   `json:"container,omitempty"`
+	Stats     *model.Stats       `json:"stats,omitempty"`
+	Engine    *model.EngineStats `json:"engine,omitempty"`
+}
+
+func NewStore(config StoreConfig) (*Store, error) {
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		config: config,
+
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	s.maintain()
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *Store) OnStats(c *model.Container, stats *model.Stats) {
+	s.append(&storeRecord{Time: sampleTime(stats), Host: s.host.get(), Container: c, Stats: stats})
+}
+
+func (s *Store) OnEngineStats(stats *model.EngineStats) {
+	s.host.set(stats.Host)
+	s.append(&storeRecord{Time: time.Now(), Host: stats.Host, Engine: stats})
+}
+
+func (s *Store) append(record *storeRecord) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	day := record.Time.UTC().Format(segmentDateLayout)
+
+	if day != s.day || s.file == nil {
+		if err := s.open(day); err != nil {
+			log.Println("Failed to open the store segment for", day, err)
+			return
+		}
+	}
+
+	if err := s.encoder.Encode(record); err != nil {
+		log.Println("Failed to write to the store", err)
+	}
+}
+
+// open switches to the segment of the given day, expects the lock to be held
+func (s *Store) open(day string) error {
+	s.closeSegment()
+
+	path := filepath.Join(s.config.Directory, segmentPrefix+day+segmentSuffix)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.day = day
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.encoder = json.NewEncoder(s.writer)
+
+	if logging.IsDebugEnabled() {
+		log.Println("Storing stats in", path)
+	}
+
+	return nil
+}
+
+// closeSegment flushes and closes the current segment, expects the lock to be held
+func (s *Store) closeSegment() {
+	if s.file == nil {
+		return
+	}
+
+	if err := s.writer.Flush(); err != nil {
+		log.Println("Failed to flush the store segment", err)
+	}
+
+	s.file.Close()
+	s.file = nil
+	s.day = ""
+}
+
+func (s *Store) flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.writer != nil {
+		if err := s.writer.Flush(); err != nil {
+			log.Println("Failed to flush the store segment", err)
+		}
+	}
+}
+
+func (s *Store) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	maintenance := time.NewTicker(storeMaintenanceInterval)
+	defer maintenance.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-maintenance.C:
+			s.maintain()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// maintain compresses the segments of the previous days,
+// and deletes the ones past the retention time
+func (s *Store) maintain() {
+	segments, err := listSegments(s.config.Directory)
+	if err != nil {
+		log.Println("Failed to list the store segments", err)
+		return
+	}
+
+	today := time.Now().UTC().Format(segmentDateLayout)
+
+	for _, segment := range segments {
+		s.maintainSegment(segment, today)
+	}
+}
+
+// maintainSegment deletes or compresses the segment while holding the lock,
+// so that late samples for its day cannot reopen it in the meantime
+func (s *Store) maintainSegment(segment segmentFile, today string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.config.Retention > 0 && segment.end().Before(time.Now().Add(-s.config.Retention)) {
+		if segment.day == s.day {
+			s.closeSegment()
+		}
+
+		if err := os.Remove(segment.path); err != nil {
+			log.Println("Failed to delete the store segment", segment.path, err)
+		} else if logging.IsDebugEnabled() {
+			log.Println("Deleted the expired store segment", segment.path)
+		}
+
+		return
+	}
+
+	if !segment.compacted && segment.day != today && segment.day != s.day {
+		if err := compactSegment(segment.path); err != nil {
+			log.Println("Failed to compact the store segment", segment.path, err)
+		}
+	}
+}
+
+func (s *Store) Close() error {
+	close(s.done)
+	<-s.stopped
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closeSegment()
+	return nil
+}
+
+type segmentFile struct {
+	path      string
+	day       string
+	compacted bool
+}
+
+func (f segmentFile) start() time.Time {
+	start, _ := time.Parse(segmentDateLayout, f.day)
+	return start
+}
+
+func (f segmentFile) end() time.Time {
+	return f.start().Add(24 * time.Hour)
+}
+
+// listSegments returns the segment files in the directory ordered by their day
+func listSegments(directory string) ([]segmentFile, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segmentFile
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, segmentPrefix) {
+			continue
+		}
+
+		segment := segmentFile{path: filepath.Join(directory, name)}
+
+		if strings.HasSuffix(name, compactedSuffix) {
+			segment.day = strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), compactedSuffix)
+			segment.compacted = true
+		} else if strings.HasSuffix(name, segmentSuffix) {
+			segment.day = strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix)
+		} else {
+			continue
+		}
+
+		if _, err := time.Parse(segmentDateLayout, segment.day); err != nil {
+			continue
+		}
+
+		segments = append(segments, segment)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].day == segments[j].day {
+			// read the compacted part of the day first
+			return segments[i].compacted && !segments[j].compacted
+		}
+
+		return segments[i].day < segments[j].day
+	})
+
+	return segments, nil
+}
+
+// compactSegment replaces the segment file with its gzip compressed version,
+// appending it as a new gzip member if the day was compacted before
+func compactSegment(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target := strings.TrimSuffix(path, segmentSuffix) + compactedSuffix
+	temporary := target + ".tmp"
+
+	if err := compressFile(source, temporary); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		err = appendFile(target, temporary)
+		os.Remove(temporary)
+
+		if err != nil {
+			return err
+		}
+	} else if err := os.Rename(temporary, target); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func compressFile(source io.Reader, path string) error {
+	destination, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	compressor := gzip.NewWriter(destination)
+
+	if _, err := io.Copy(compressor, source); err != nil {
+		return err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	return destination.Close()
+}
+
+func appendFile(target, path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return err
+	}
+
+	return destination.Close()
+}
+
+// scanStore calls the function with every record stored since the given time
+func scanStore(directory string, since time.Time, fn func(*storeRecord)) error {
+	segments, err := listSegments(directory)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if segment.end().Before(since) {
+			continue
+		}
+
+		if err := scanSegment(segment, since, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanSegment(segment segmentFile, since time.Time, fn func(*storeRecord)) error {
+	file, err := os.Open(segment.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if segment.compacted {
+		decompressor, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer decompressor.Close()
+
+		reader = decompressor
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var record storeRecord
+
+		// the last line can be incomplete after a crash
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			if logging.IsDebugEnabled() {
+				log.Println("Skipping an invalid record in", segment.path, err)
+			}
+
+			continue
+		}
+
+		if !record.Time.Before(since) {
+			fn(&record)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rycus86/container-metrics/model"
+)
+
+func TestStoreAndExport(t *testing.T) {
+	directory, err := ioutil.TempDir("", "cntm-store")
+	if err != nil {
+		t.Fatal("Failed to create a temporary directory:", err)
+	}
+	defer os.RemoveAll(directory)
+
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+
+	// an expired segment that should be deleted on start
+	expired := filepath.Join(directory, "cntm-2000-01-01.jsonl")
+	ioutil.WriteFile(expired, []byte("{}\n"), 0644)
+
+	store, err := NewStore(StoreConfig{Directory: directory, Retention: 72 * time.Hour, Interval: time.Hour})
+	if err != nil {
+		t.Fatal("Failed to create the store:", err)
+	}
+
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Error("Expected the expired segment to be deleted")
+	}
+
+	container := &model.Container{Id: "abcd", Name: "web", Image: "nginx", Labels: map[string]string{"com.example": "x"}}
+
+	store.OnEngineStats(&model.EngineStats{Host: "pi-01", Images: 3})
+	store.OnStats(container, &model.Stats{Read: yesterday, CpuStats: model.CpuStats{Percent: 10}})
+	store.OnStats(container, &model.Stats{Read: now, CpuStats: model.CpuStats{Percent: 20}})
+
+	// compact the segment of yesterday, then append a late sample to it
+	store.Close()
+	store.maintain()
+
+	store, _ = NewStore(StoreConfig{Directory: directory, Interval: time.Hour})
+	store.host.set("pi-01")
+	store.OnStats(container, &model.Stats{Read: yesterday.Add(time.Second), CpuStats: model.CpuStats{Percent: 15}})
+	store.Close()
+	store.maintain()
+
+	segments, _ := listSegments(directory)
+	if len(segments) != 2 || !segments[0].compacted || segments[1].compacted {
+		t.Fatalf("Unexpected segments: %+v", segments)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Export(buf, directory, yesterday.Add(-time.Minute), "openmetrics"); err != nil {
+		t.Fatal("Failed to export:", err)
+	}
+
+	exported := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE cntm_cpu_usage_percent gauge\n",
+		`cntm_cpu_usage_percent{com_example="x",container_image="nginx",container_name="web",engine_host="pi-01"} 10 `,
+		`cntm_cpu_usage_percent{com_example="x",container_image="nginx",container_name="web",engine_host="pi-01"} 15 `,
+		`cntm_cpu_usage_percent{com_example="x",container_image="nginx",container_name="web",engine_host="pi-01"} 20 `,
+		`cntm_engine_num_images{engine_host="pi-01"} 3 `,
+	} {
+		if !strings.Contains(exported, expected) {
+			t.Error("Missing from the export:", expected)
+		}
+	}
+
+	if !strings.HasSuffix(exported, "# EOF\n") {
+		t.Error("Expected the export to end with EOF")
+	}
+
+	buf.Reset()
+	if err := Export(buf, directory, now.Add(-time.Minute), "csv"); err != nil {
+		t.Fatal("Failed to export:", err)
+	}
+
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(rows) != 2 || !strings.HasPrefix(rows[0], "time,engine_host,container_id") || !strings.Contains(rows[1], ",web,nginx,") {
+		t.Error("Unexpected CSV export:", rows)
+	}
+}
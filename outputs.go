@@ -0,0 +1,269 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/rycus86/container-metrics/metrics"
+	"github.com/rycus86/container-metrics/output"
+)
+
+type outputFlags struct {
+	influxDB output.InfluxDBConfig
+	graphite output.GraphiteConfig
+	statsD   output.StatsDConfig
+	otlp     output.OTLPConfig
+
+	remoteWrite output.RemoteWriteConfig
+	pushgateway output.PushgatewayConfig
+
+	store output.StoreConfig
+	file  output.FileConfig
+
+	fileLabels string
+
+	otlpHeaders       string
+	remoteWriteLabels string
+}
+
+func (f *outputFlags) register(fs *flag.FlagSet) {
+	// InfluxDB
+	fs.StringVar(&f.influxDB.URL, "influxdb-url", "",
+		"InfluxDB URL to write the metrics to")
+	fs.StringVar(&f.influxDB.Database, "influxdb-database", "",
+		"InfluxDB 1.x database to write to")
+	fs.StringVar(&f.influxDB.RetentionPolicy, "influxdb-retention-policy", "",
+		"InfluxDB 1.x retention policy to write to")
+	fs.StringVar(&f.influxDB.Username, "influxdb-username", "",
+		"InfluxDB 1.x username")
+	fs.StringVar(&f.influxDB.Password, "influxdb-password", "",
+		"InfluxDB 1.x password")
+	fs.StringVar(&f.influxDB.Org, "influxdb-org", "",
+		"InfluxDB 2.x organization")
+	fs.StringVar(&f.influxDB.Bucket, "influxdb-bucket", "",
+		"InfluxDB 2.x bucket to write to")
+	fs.StringVar(&f.influxDB.Token, "influxdb-token", "",
+		"InfluxDB 2.x authentication token")
+	fs.IntVar(&f.influxDB.BatchSize, "influxdb-batch-size", 1000,
+		"Maximum number of lines to write to InfluxDB at once")
+	fs.IntVar(&f.influxDB.MaxBuffered, "influxdb-buffer", 100000,
+		"Maximum number of lines to keep while InfluxDB is unavailable")
+
+	// Graphite
+	fs.StringVar(&f.graphite.Address, "graphite-address", "",
+		"Graphite (Carbon) address to send the metrics to as host:port")
+	fs.StringVar(&f.graphite.Protocol, "graphite-protocol", "tcp",
+		"Protocol to send the metrics to Graphite with (tcp or udp)")
+	fs.StringVar(&f.graphite.Template, "graphite-template", output.DefaultGraphiteTemplate,
+		"Template for the Graphite path of container metrics")
+	fs.StringVar(&f.graphite.EngineTemplate, "graphite-engine-template", output.DefaultGraphiteEngineTemplate,
+		"Template for the Graphite path of engine metrics")
+	fs.IntVar(&f.graphite.MaxBuffered, "graphite-buffer", 100000,
+		"Maximum number of lines to keep while Graphite is unavailable")
+
+	// StatsD
+	fs.StringVar(&f.statsD.Address, "statsd-address", "",
+		"StatsD address to send the metrics to as host:port or socket path")
+	fs.StringVar(&f.statsD.Protocol, "statsd-protocol", "udp",
+		"Protocol to send the metrics to StatsD with (udp or unixgram)")
+	fs.StringVar(&f.statsD.Prefix, "statsd-prefix", "cntm",
+		"Prefix for the StatsD metric names")
+	fs.BoolVar(&f.statsD.Tags, "statsd-tags", false,
+		"Send the container metadata as DogStatsD tags")
+	fs.IntVar(&f.statsD.MaxPacketSize, "statsd-packet-size", 1432,
+		"Maximum size of the packets sent to StatsD")
+
+	// OpenTelemetry
+	fs.StringVar(&f.otlp.Endpoint, "otlp-endpoint", "",
+		"OTLP/HTTP endpoint to export the metrics to")
+	fs.StringVar(&f.otlp.Encoding, "otlp-encoding", "protobuf",
+		"Encoding of the OTLP requests (protobuf or json)")
+	fs.StringVar(&f.otlpHeaders, "otlp-headers", "",
+		"Headers to send with the OTLP requests (comma separated key=value pairs)")
+
+	// Prometheus remote write
+	fs.StringVar(&f.remoteWrite.URL, "remote-write-url", "",
+		"Prometheus remote write URL to push the metrics to")
+	fs.StringVar(&f.remoteWrite.Username, "remote-write-username", "",
+		"Username for basic authentication with remote write")
+	fs.StringVar(&f.remoteWrite.Password, "remote-write-password", "",
+		"Password for basic authentication with remote write")
+	fs.StringVar(&f.remoteWrite.BearerToken, "remote-write-bearer-token", "",
+		"Bearer token for authentication with remote write")
+	fs.StringVar(&f.remoteWriteLabels, "remote-write-labels", "",
+		"External labels to add to the metrics pushed with remote write (comma separated key=value pairs)")
+	fs.IntVar(&f.remoteWrite.MaxPending, "remote-write-queue", 120,
+		"Maximum number of remote write requests to keep while the endpoint is unavailable")
+
+	// Prometheus Pushgateway
+	fs.StringVar(&f.pushgateway.URL, "pushgateway-url", "",
+		"Prometheus Pushgateway URL to push the metrics to")
+	fs.StringVar(&f.pushgateway.Job, "pushgateway-job", "container-metrics",
+		"Job name to push the metrics to the Pushgateway with")
+	fs.BoolVar(&f.pushgateway.DeleteOnExit, "pushgateway-delete-on-exit", false,
+		"Delete the metrics from the Pushgateway on exit")
+
+	// Local store
+	fs.StringVar(&f.store.Directory, "store-dir", "",
+		"Directory to record every sample in on disk")
+	fs.DurationVar(&f.store.Retention, "store-retention", 7*24*time.Hour,
+		"Time to keep the recorded samples on disk for")
+
+	// File
+	fs.StringVar(&f.file.Path, "file-path", "",
+		"File to write every sample to, or - for the standard output")
+	fs.StringVar(&f.file.Format, "file-format", "json",
+		"Format to write the samples to the file in (json or csv)")
+	fs.StringVar(&f.fileLabels, "file-labels", "",
+		"Container labels to write to the CSV file as columns (comma separated)")
+	fs.Int64Var(&f.file.MaxSize, "file-max-size", 100*1024*1024,
+		"Maximum size of the file in bytes before rotating it")
+	fs.DurationVar(&f.file.MaxAge, "file-max-age", 24*time.Hour,
+		"Maximum age of the file before rotating it")
+	fs.IntVar(&f.file.MaxBackups, "file-max-backups", 7,
+		"Maximum number of rotated files to keep")
+}
+
+// create sets up the configured outputs, closing the ones already created if any of them fails
+func (f outputFlags) create(interval, timeout time.Duration) (outputs []output.Output, err error) {
+	defer func() {
+		if err != nil {
+			closeOutputs(outputs)
+			outputs = nil
+		}
+	}()
+
+	if f.influxDB.URL != "" {
+		f.influxDB.Interval = interval
+		f.influxDB.Timeout = timeout
+
+		influxDB, err := output.NewInfluxDB(f.influxDB)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the InfluxDB output")
+		}
+
+		log.Println("Writing metrics to InfluxDB at", f.influxDB.URL)
+		outputs = append(outputs, influxDB)
+	}
+
+	if f.graphite.Address != "" {
+		f.graphite.Interval = interval
+		f.graphite.Timeout = timeout
+
+		graphite, err := output.NewGraphite(f.graphite)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the Graphite output")
+		}
+
+		log.Println("Sending metrics to Graphite at", f.graphite.Address)
+		outputs = append(outputs, graphite)
+	}
+
+	if f.statsD.Address != "" {
+		statsD, err := output.NewStatsD(f.statsD)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the StatsD output")
+		}
+
+		log.Println("Sending metrics to StatsD at", f.statsD.Address)
+		outputs = append(outputs, statsD)
+	}
+
+	if f.otlp.Endpoint != "" {
+		f.otlp.Interval = interval
+		f.otlp.Timeout = timeout
+		f.otlp.Headers = parseKeyValues(f.otlpHeaders)
+
+		otlp, err := output.NewOTLP(f.otlp)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the OTLP output")
+		}
+
+		log.Println("Exporting metrics with OTLP to", f.otlp.Endpoint)
+		outputs = append(outputs, otlp)
+	}
+
+	if f.remoteWrite.URL != "" {
+		f.remoteWrite.Interval = interval
+		f.remoteWrite.Timeout = timeout
+		f.remoteWrite.ExternalLabels = parseKeyValues(f.remoteWriteLabels)
+		f.remoteWrite.Gatherer = metrics.Gatherer
+
+		remoteWrite, err := output.NewRemoteWrite(f.remoteWrite)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the remote write output")
+		}
+
+		log.Println("Pushing metrics with remote write to", f.remoteWrite.URL)
+		outputs = append(outputs, remoteWrite)
+	}
+
+	if f.pushgateway.URL != "" {
+		f.pushgateway.Interval = interval
+		f.pushgateway.Timeout = timeout
+		f.pushgateway.Gatherer = metrics.Gatherer
+
+		pushgateway, err := output.NewPushgateway(f.pushgateway)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the Pushgateway output")
+		}
+
+		log.Println("Pushing metrics to the Pushgateway at", f.pushgateway.URL)
+		outputs = append(outputs, pushgateway)
+	}
+
+	if f.store.Directory != "" {
+		f.store.Interval = interval
+
+		store, err := output.NewStore(f.store)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the local store")
+		}
+
+		log.Println("Recording metrics on disk in", f.store.Directory)
+		outputs = append(outputs, store)
+	}
+
+	if f.file.Path != "" {
+		f.file.Interval = interval
+
+		if f.fileLabels != "" {
+			f.file.Labels = strings.Split(f.fileLabels, ",")
+		}
+
+		file, err := output.NewFile(f.file)
+		if err != nil {
+			return outputs, errors.Wrap(err, "Failed to set up the file output")
+		}
+
+		log.Println("Writing samples to", f.file.Path)
+		outputs = append(outputs, file)
+	}
+
+	return outputs, nil
+}
+
+func closeOutputs(outputs []output.Output) {
+	for _, out := range outputs {
+		if err := out.Close(); err != nil {
+			log.Println("Failed to close the output", err)
+		}
+	}
+}
+
+// parseKeyValues parses comma separated key=value pairs
+func parseKeyValues(value string) map[string]string {
+	pairs := map[string]string{}
+
+	for _, item := range strings.Split(value, ",") {
+		if parts := strings.SplitN(item, "=", 2); len(parts) == 2 {
+			pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return pairs
+}
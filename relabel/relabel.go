@@ -0,0 +1,262 @@
+package relabel
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rycus86/container-metrics/config"
+)
+
+const (
+	// ContainerName is the label holding the name of the container during relabeling
+	ContainerName = "__container_name__"
+	// ContainerImage is the label holding the image of the container during relabeling
+	ContainerImage = "__container_image__"
+	// ContainerID is the label holding the ID of the container during relabeling
+	ContainerID = "__container_id__"
+
+	internalPrefix = "__"
+)
+
+// Config is a relabeling rule, following the relabel_configs of Prometheus
+type Config struct {
+	Action       string
+	SourceLabels []string
+	Separator    string
+	Regex        string
+	TargetLabel  string
+	Replacement  string
+	Modulus      uint64
+}
+
+// Rule is a validated relabeling rule
+type Rule struct {
+	Config
+
+	regex *regexp.Regexp
+}
+
+// Load reads the relabeling rules from the relabel_configs list of the YAML file
+func Load(path string) ([]Config, error) {
+	values, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := values["relabel_configs"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a relabel_configs list", path)
+	}
+
+	configs := make([]Config, len(items))
+
+	for idx, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: rule %d: expected a map", path, idx+1)
+		}
+
+		if err := configs[idx].parse(fields); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %s", path, idx+1, err)
+		}
+	}
+
+	return configs, nil
+}
+
+func (c *Config) parse(fields map[string]interface{}) error {
+	for key, value := range fields {
+		if key == "source_labels" {
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("expected a list for source_labels")
+			}
+
+			for _, item := range items {
+				label, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("expected label names in source_labels")
+				}
+
+				c.SourceLabels = append(c.SourceLabels, label)
+			}
+
+			continue
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a plain value for %s", key)
+		}
+
+		switch key {
+		case "action":
+			c.Action = text
+		case "separator":
+			c.Separator = text
+		case "regex":
+			c.Regex = text
+		case "target_label":
+			c.TargetLabel = text
+		case "replacement":
+			c.Replacement = text
+		case "modulus":
+			modulus, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid modulus: %s", text)
+			}
+
+			c.Modulus = modulus
+		default:
+			return fmt.Errorf("unknown field: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// Compile validates the rules and sets the defaults of Prometheus for the missing fields,
+// rejecting the target labels the reserved function returns true for, if given
+func Compile(configs []Config, reserved func(label string) bool) ([]*Rule, error) {
+	rules := make([]*Rule, len(configs))
+
+	for idx, c := range configs {
+		if c.Action == "" {
+			c.Action = "replace"
+		}
+		if c.Separator == "" {
+			c.Separator = ";"
+		}
+		if c.Regex == "" {
+			c.Regex = "(.*)"
+		}
+		if c.Replacement == "" {
+			c.Replacement = "$1"
+		}
+
+		regex, err := regexp.Compile("^(?:" + c.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid regex: %s", idx+1, err)
+		}
+
+		switch c.Action {
+		case "replace", "hashmod":
+			if c.TargetLabel == "" {
+				return nil, fmt.Errorf("rule %d: target_label is required for %s", idx+1, c.Action)
+			}
+			if reserved != nil && !strings.Contains(c.TargetLabel, "$") && reserved(c.TargetLabel) {
+				return nil, fmt.Errorf("rule %d: target_label clashes with a label of the metrics: %s", idx+1, c.TargetLabel)
+			}
+			if c.Action == "hashmod" && c.Modulus == 0 {
+				return nil, fmt.Errorf("rule %d: modulus is required for hashmod", idx+1)
+			}
+		case "keep", "drop":
+			if len(c.SourceLabels) == 0 {
+				return nil, fmt.Errorf("rule %d: source_labels are required for %s", idx+1, c.Action)
+			}
+		case "labelmap", "labeldrop", "labelkeep":
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action: %s", idx+1, c.Action)
+		}
+
+		rules[idx] = &Rule{Config: c, regex: regex}
+	}
+
+	return rules, nil
+}
+
+// Process applies the rules to the labels in order, and returns the resulting labels
+// without the internal ones, or false if the rules dropped the container
+func Process(rules []*Rule, labels map[string]string) (map[string]string, bool) {
+	result := make(map[string]string, len(labels))
+	for name, value := range labels {
+		result[name] = value
+	}
+
+	for _, rule := range rules {
+		if !rule.apply(result) {
+			return nil, false
+		}
+	}
+
+	for name, value := range result {
+		if strings.HasPrefix(name, internalPrefix) || value == "" {
+			delete(result, name)
+		}
+	}
+
+	return result, true
+}
+
+func (r *Rule) apply(labels map[string]string) bool {
+	values := make([]string, len(r.SourceLabels))
+	for idx, name := range r.SourceLabels {
+		values[idx] = labels[name]
+	}
+
+	source := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case "keep":
+		return r.regex.MatchString(source)
+
+	case "drop":
+		return !r.regex.MatchString(source)
+
+	case "replace":
+		match := r.regex.FindStringSubmatchIndex(source)
+		if match == nil {
+			return true
+		}
+
+		target := string(r.regex.ExpandString(nil, r.TargetLabel, source, match))
+		value := string(r.regex.ExpandString(nil, r.Replacement, source, match))
+
+		if value == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = value
+		}
+
+	case "hashmod":
+		sum := md5.Sum([]byte(source))
+		labels[r.TargetLabel] = strconv.FormatUint(binary.BigEndian.Uint64(sum[8:])%r.Modulus, 10)
+
+	case "labelmap":
+		for name, value := range copyLabels(labels) {
+			if r.regex.MatchString(name) {
+				labels[r.regex.ReplaceAllString(name, r.Replacement)] = value
+			}
+		}
+
+	case "labeldrop":
+		for name := range copyLabels(labels) {
+			if r.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+
+	case "labelkeep":
+		// the internal labels are kept for the rules after this one
+		for name := range copyLabels(labels) {
+			if !strings.HasPrefix(name, internalPrefix) && !r.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	}
+
+	return true
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for name, value := range labels {
+		copied[name] = value
+	}
+
+	return copied
+}
@@ -0,0 +1,125 @@
+package relabel
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRelabel(t *testing.T) {
+	rules, err := Compile([]Config{
+		{Action: "drop", SourceLabels: []string{ContainerName}, Regex: "buildkit.*"},
+		{SourceLabels: []string{"com.docker.compose.service"}, TargetLabel: "service"},
+		{SourceLabels: []string{ContainerImage}, Regex: ".*:(.+)", TargetLabel: "image_tag"},
+		{Action: "labelmap", Regex: "com\\.example\\.(.+)", Replacement: "example_$1"},
+		{Action: "labeldrop", Regex: "com\\..*"},
+		{Action: "hashmod", SourceLabels: []string{ContainerID}, TargetLabel: "shard", Modulus: 4},
+	}, nil)
+	if err != nil {
+		t.Fatal("Failed to compile the rules:", err)
+	}
+
+	labels, keep := Process(rules, map[string]string{
+		ContainerName:                "web",
+		ContainerImage:               "nginx:1.15",
+		ContainerID:                  "abcd",
+		"com.docker.compose.service": "frontend",
+		"com.example.team":           "ops",
+	})
+
+	if !keep {
+		t.Fatal("Expected the container to be kept")
+	}
+
+	expected := map[string]string{
+		"service":      "frontend",
+		"image_tag":    "1.15",
+		"example_team": "ops",
+		"shard":        labels["shard"],
+	}
+
+	if !reflect.DeepEqual(labels, expected) {
+		t.Error("Unexpected labels:", labels)
+	}
+
+	if shard := labels["shard"]; shard < "0" || shard > "3" || len(shard) != 1 {
+		t.Error("Unexpected shard:", shard)
+	}
+
+	if _, keep := Process(rules, map[string]string{ContainerName: "buildkit_1"}); keep {
+		t.Error("Expected the container to be dropped")
+	}
+}
+
+func TestHashmodMatchesPrometheus(t *testing.T) {
+	rules, _ := Compile([]Config{{Action: "hashmod", SourceLabels: []string{"a"}, TargetLabel: "mod", Modulus: 1000}}, nil)
+
+	// the lower 8 bytes of md5("foo") = acbd18db4cc2f85cedef654fccc4a4d8, modulo 1000
+	labels, _ := Process(rules, map[string]string{"a": "foo"})
+
+	if labels["mod"] != "696" {
+		t.Error("Unexpected hashmod value:", labels["mod"])
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, config := range []Config{
+		{Action: "unknown"},
+		{Action: "replace"},
+		{Action: "keep"},
+		{Action: "hashmod", TargetLabel: "x"},
+		{Action: "labeldrop", Regex: "("},
+	} {
+		if _, err := Compile([]Config{config}, nil); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestReservedTargetLabels(t *testing.T) {
+	reserved := func(label string) bool { return label == "container_name" }
+
+	if _, err := Compile([]Config{{SourceLabels: []string{"a"}, TargetLabel: "container_name"}}, reserved); err == nil {
+		t.Error("Expected an error for the reserved target label")
+	}
+	if _, err := Compile([]Config{{Action: "hashmod", SourceLabels: []string{"a"}, TargetLabel: "container_name", Modulus: 2}}, reserved); err == nil {
+		t.Error("Expected an error for the reserved hashmod target label")
+	}
+	if _, err := Compile([]Config{{SourceLabels: []string{"a"}, TargetLabel: "service"}}, reserved); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file, err := ioutil.TempFile("", "cntm-relabel")
+	if err != nil {
+		t.Fatal("Failed to create a temporary file:", err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(`
+relabel_configs:
+  - source_labels: [com.docker.compose.service]
+    target_label: service
+  - action: hashmod
+    source_labels: [__container_id__]
+    target_label: shard
+    modulus: 8
+`)
+	file.Close()
+
+	configs, err := Load(file.Name())
+	if err != nil {
+		t.Fatal("Failed to load the rules:", err)
+	}
+
+	expected := []Config{
+		{SourceLabels: []string{"com.docker.compose.service"}, TargetLabel: "service"},
+		{Action: "hashmod", SourceLabels: []string{"__container_id__"}, TargetLabel: "shard", Modulus: 8},
+	}
+
+	if !reflect.DeepEqual(configs, expected) {
+		t.Errorf("Unexpected rules: %+v", configs)
+	}
+}
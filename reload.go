@@ -0,0 +1,158 @@
+package main
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/rycus86/container-metrics/alert"
+	"github.com/rycus86/container-metrics/logging"
+	"github.com/rycus86/container-metrics/metrics"
+	"github.com/rycus86/container-metrics/output"
+)
+
+// reload reads the configuration again, and applies the changes without
+// restarting the HTTP server, or keeps the current configuration if the new one is invalid
+func (mc *MetricsCollector) reload() {
+	updated, err := loadOptions(os.Args[1:])
+	if err != nil {
+		log.Println("Invalid configuration, keeping the current one:", err)
+		return
+	}
+
+	current := mc.options
+
+	if updated.port != current.port || updated.dockerHost != current.dockerHost || updated.onScrape != current.onScrape {
+		log.Println("Changing the HTTP port, the Docker host or the scrape mode requires a restart, keeping the current values")
+
+		updated.port = current.port
+		updated.dockerHost = current.dockerHost
+		updated.onScrape = current.onScrape
+	}
+
+	if err := metrics.SetEnabledCollectors(updated.collectors); err != nil {
+		log.Println("Invalid configuration, keeping the current one:", err)
+		return
+	}
+
+	recreateOutputs := !reflect.DeepEqual(updated.outputs, current.outputs) ||
+		updated.interval != current.interval || updated.timeout != current.timeout
+
+	if recreateOutputs {
+		// close the current outputs first, so that the file outputs and the local store
+		// are not written by two outputs at the same time
+		mc.replaceOutputs(nil)
+
+		outputs, err := updated.outputs.create(updated.interval, updated.timeout)
+		if err != nil {
+			log.Println("Invalid configuration, keeping the current one:", err)
+
+			closeOutputs(outputs)
+			metrics.SetEnabledCollectors(current.collectors)
+
+			if outputs, err = current.outputs.create(current.interval, current.timeout); err != nil {
+				log.Println("Failed to set up the current outputs again:", err)
+			}
+
+			mc.replaceOutputs(outputs)
+			return
+		}
+
+		mc.replaceOutputs(outputs)
+	}
+
+	logging.Setup(updated.debug, updated.verbose)
+
+	metrics.SetNaming(updated.namespace, updated.naming)
+	metrics.SetMetricNames(parseKeyValues(updated.metricNames))
+	metrics.SetInfoLabels(updated.infoLabels)
+	metrics.SetLimits(updated.limits())
+
+	mc.client.Configure(updated.timeout, updated.labelFilters())
+	mc.client.SetRelabelRules(updated.relabelRules)
+	mc.client.SetContainerFilter(updated.filter)
+
+	if updated.interval != current.interval {
+		mc.ticker.Stop()
+		mc.ticker = time.NewTicker(updated.interval)
+	}
+
+	metrics.SetTimestamps(updated.timestamps)
+	metrics.SetMaxStreamClients(updated.streamClients)
+
+	if updated.history != current.history || updated.historySize() != current.historySize() {
+		metrics.SetHistory(updated.history, updated.historySize())
+	}
+
+	if updated.onScrape {
+		metrics.CollectOnScrape(mc.statsFunc, mc.client.GetEngineStats, updated.scrapeCache, updated.timeout)
+	}
+
+	if !reflect.DeepEqual(updated.alertConfig, current.alertConfig) {
+		mc.reloadAlerts(updated.alertConfig)
+	}
+
+	if updated.containersChanged(current) || !reflect.DeepEqual(updated.collectors, current.collectors) {
+		// the containers, their labels and the enabled collectors define the metrics
+		go mc.reloadContainers()
+	}
+
+	mc.options = updated
+
+	log.Println("Configuration reloaded")
+}
+
+// replaceOutputs closes the current outputs, and starts sending the samples to the new ones
+func (mc *MetricsCollector) replaceOutputs(outputs []output.Output) {
+	for _, out := range mc.outputs {
+		metrics.RemoveListener(out)
+	}
+
+	closeOutputs(mc.outputs)
+
+	for _, out := range outputs {
+		metrics.AddListener(out)
+	}
+
+	mc.outputs = outputs
+}
+
+// reloadAlerts replaces the alerting engine, keeping the state of the alerts
+// for the rules that still exist, and resolving the ones of the removed rules
+func (mc *MetricsCollector) reloadAlerts(config *alert.Config) {
+	var alerts *alert.Engine
+
+	if config != nil {
+		var err error
+
+		if alerts, err = alert.NewEngine(*config); err != nil {
+			log.Println("Failed to set up the alerting rules:", err)
+			return
+		}
+	}
+
+	if alerts != nil {
+		metrics.AddListener(alerts)
+		mc.client.SetEventHandler(alerts.OnEvent)
+	} else {
+		mc.client.SetEventHandler(nil)
+	}
+
+	if mc.alerts != nil {
+		metrics.RemoveListener(mc.alerts)
+		mc.alerts.HandOver(alerts)
+	}
+
+	mc.alerts = alerts
+}
+
+func (mc *MetricsCollector) reloadContainers() {
+	containers, err := mc.client.GetContainers()
+	if err != nil {
+		log.Println("Failed to reload the containers", err)
+		return
+	}
+
+	mc.updates <- containers
+}